@@ -2,20 +2,39 @@ package app
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"go-project-278/Internal/geoip"
 	"go-project-278/Internal/handler"
 	"go-project-278/Internal/repository"
+	"go-project-278/Internal/shortcode"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
-	"database/sql"
+	_ "github.com/lib/pq"
 )
 
 type App struct {
 	Ctx       context.Context
-	Repo      *repository.Repository
+	Repo      repository.PostRepository
 	Handler   *handler.App
+	db        *sql.DB
 }
 
-func NewApp(ctx context.Context, dbpool *sql.DB) *App {
-	repo := repository.NewLinkRepository(dbpool)
+// NewApp wires the handlers to the storage named by databaseURL. The scheme
+// picks the backend:
+//
+//	postgres://..., postgresql://...   Postgres (schema managed by goose in run.sh)
+//	sqlite:///var/lib/shortener.db     SQLite file, migrated on startup
+//	memory://                          in-process store, lost on restart
+func NewApp(ctx context.Context, databaseURL string) (*App, error) {
+	repo, database, err := openRepository(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
 	handlerApp := &handler.App{
 		Ctx:  ctx,
 		Repo: repo,
@@ -24,10 +43,117 @@ func NewApp(ctx context.Context, dbpool *sql.DB) *App {
 		Ctx:       ctx,
 		Repo:      repo,
 		Handler:   handlerApp,
-	}
+		db:        database,
+	}, nil
 }
 
 func (a *App) Routes(r *gin.Engine) {
 	a.Handler.Routes(r)
 }
 
+// LoadGeoIP enables geo targeting with the IP range database at path and
+// reloads it whenever the file changes, checking every reloadEvery.
+func (a *App) LoadGeoIP(path string, reloadEvery time.Duration) error {
+	resolver, err := geoip.Open(path)
+	if err != nil {
+		return err
+	}
+	a.Handler.Geo = resolver
+	go resolver.Watch(a.Ctx, reloadEvery)
+	return nil
+}
+
+// ConfigureShortCodes selects how short names are generated for links
+// created without one. The sequence strategies draw from the repository.
+// With case-insensitive short names the codes are lower-case.
+func (a *App) ConfigureShortCodes(cfg shortcode.Config) error {
+	cfg.Lower = cfg.Lower || a.Handler.CaseInsensitive
+	codes, err := shortcode.New(cfg, a.Repo)
+	if err != nil {
+		return err
+	}
+	a.Handler.Codes = codes
+	return nil
+}
+
+// UseCaseInsensitiveShortNames makes short names unique regardless of case
+// and Redirect ignore case. Call it before ConfigureShortCodes.
+func (a *App) UseCaseInsensitiveShortNames() {
+	a.Handler.CaseInsensitive = true
+}
+
+// SetBaseURL sets the public address short URLs are built on, such as
+// "https://sho.rt". A path is kept, so the service can live under a prefix.
+func (a *App) SetBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("base URL %q: want http(s)://host[/path]", raw)
+	}
+	a.Handler.BaseURL = strings.TrimSuffix(u.String(), "/")
+	return nil
+}
+
+// LoadReservedNames reserves the short names listed in the file at path,
+// one per line, on top of the built-in ones. Blank lines and lines starting
+// with "#" are skipped; case is ignored.
+func (a *App) LoadReservedNames(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reserved names: %w", err)
+	}
+	names := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			names[strings.ToLower(line)] = true
+		}
+	}
+	a.Handler.ReservedNames = names
+	return nil
+}
+
+// Close releases the database connection, if the backend holds one.
+func (a *App) Close() error {
+	if a.db == nil {
+		return nil
+	}
+	return a.db.Close()
+}
+
+func openRepository(ctx context.Context, databaseURL string) (repository.PostRepository, *sql.DB, error) {
+	scheme, rest, ok := strings.Cut(databaseURL, ":")
+	if !ok {
+		return nil, nil, fmt.Errorf("database url %q has no scheme", databaseURL)
+	}
+	switch strings.ToLower(scheme) {
+	case "memory":
+		return repository.NewMemoryRepository(), nil, nil
+	case "sqlite", "sqlite3":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return nil, nil, fmt.Errorf("database url %q has no sqlite path", databaseURL)
+		}
+		database, err := repository.OpenSQLite(ctx, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repository.NewSQLiteRepository(database), database, nil
+	case "postgres", "postgresql":
+		database, err := sql.Open("postgres", databaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open postgres: %w", err)
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := database.PingContext(pingCtx); err != nil {
+			database.Close()
+			return nil, nil, fmt.Errorf("ping postgres: %w", err)
+		}
+		return repository.NewLinkRepository(database), database, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
@@ -2,9 +2,13 @@
 package dto
 
 import (
+    "fmt"
     "regexp"
     "time"
+    "net/http"
     "net/url"
+    "strings"
+    "unicode/utf8"
 )
 
 type Visit struct {
@@ -12,13 +16,153 @@ type Visit struct {
     LinkID    int       `json:"link_id" db:"link_id"`
     IP        string    `json:"ip" db:"ip"`
     UserAgent string    `json:"user_agent" db:"user_agent"`
+    // UAFamily is the browser or client family parsed from UserAgent.
+    UAFamily  string    `json:"ua_family,omitempty" db:"ua_family"`
+    Referer   string    `json:"referer,omitempty" db:"referer"`
+    // RefererDomain is the referring host, lower-cased and without "www.".
+    RefererDomain string `json:"referer_domain,omitempty" db:"referer_domain"`
     Status    int       `json:"status" db:"status"`
+    // Target is the targeting rule that chose the destination, "" for the default.
+    Target    string    `json:"target,omitempty" db:"target"`
+    // Variant is the name of the A/B variant that was served, if any.
+    Variant   string    `json:"variant,omitempty" db:"variant"`
+    // Country is the visitor's country code from the GeoIP database, "" if unknown.
+    Country   string    `json:"country,omitempty" db:"country"`
+    // Fallback marks a redirect to the expired_url or inactive_url of the
+    // link; it is not a click on the link.
+    Fallback  bool      `json:"fallback,omitempty" db:"fallback"`
     CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// What Redirect does once a link's expires_at has passed.
+const (
+    ExpiredActionGone     = "gone"     // 410 Gone with a JSON error
+    ExpiredActionFallback = "fallback" // redirect to expired_url instead
+    ExpiredActionPage     = "page"     // 410 with a small HTML page
+)
+
+// Which value wins when a passed-through query parameter is already set on
+// the destination URL.
+const (
+    QueryPrecedenceDestination = "destination"
+    QueryPrecedenceRequest     = "request"
+)
+
+// Device classes a TargetRule can match on.
+const (
+    TargetIOS     = "ios"
+    TargetAndroid = "android"
+    TargetWindows = "windows"
+    TargetMacOS   = "macos"
+    TargetLinux   = "linux"
+    TargetMobile  = "mobile"
+    TargetDesktop = "desktop"
+)
+
+// VisitFilter narrows GET /api/link_visits. Zero fields do not filter,
+// except IDs: when not nil it keeps only those visits, so an empty list
+// matches none. The created_at bounds are inclusive.
+type VisitFilter struct {
+    IDs         []int
+    LinkID      *int
+    IP          string
+    Status      *int
+    CreatedFrom *time.Time
+    CreatedTo   *time.Time
+}
+
+// VisitQuery is a filtered, sorted page of visits. Sort is a column name
+// from VisitSortColumns, "" for newest first. Limit <= 0 returns every row
+// from Start on.
+type VisitQuery struct {
+    VisitFilter
+    Sort  string
+    Desc  bool
+    Start int
+    Limit int
+}
+
+// VisitSortColumns are the columns GET /api/link_visits can sort by.
+var VisitSortColumns = []string{
+    "id", "link_id", "ip", "user_agent", "status", "created_at",
+    "target", "variant", "country", "referer", "referer_domain", "ua_family",
+}
+
+// LinkFilter narrows GET /api/links. IDs, when not nil, keeps only those
+// links, so an empty list matches none. Q is a case-insensitive substring
+// of original_url or short_name; Domain and Tag match exactly. The
+// created_at bounds are inclusive.
+type LinkFilter struct {
+    IDs         []int
+    Q           string
+    Domain      string
+    Tag         string
+    CreatedFrom *time.Time
+    CreatedTo   *time.Time
+}
+
+// LinkQuery is a filtered, sorted page of links. Sort is a column name from
+// LinkSortColumns, "" for ascending id. Limit <= 0 returns every row from
+// Start on.
+type LinkQuery struct {
+    LinkFilter
+    Sort  string
+    Desc  bool
+    Start int
+    Limit int
+}
+
+// LinkSortColumns are the columns GET /api/links can sort by.
+var LinkSortColumns = []string{
+    "id", "original_url", "short_name", "title", "original_domain",
+    "clicks", "created_at", "expires_at",
+}
+
+// Bucket sizes of the stats timeline.
+const (
+    StatsIntervalDay  = "day"
+    StatsIntervalHour = "hour"
+)
+
+// StatsQuery selects the visits of one link in [From, To) for VisitStats.
+// Top caps the referrer and user-agent lists.
+type StatsQuery struct {
+    LinkID   int
+    From     time.Time
+    To       time.Time
+    Interval string
+    Top      int
+}
+
 type LinkRequest struct {
-    Original_url string `json:"original_url" binding:"required"`
-    Short_name   string `json:"short_name,omitempty" binding:"omitempty,min=3,max=32"`
+    Original_url   string     `json:"original_url" binding:"required"`
+    Short_name     string     `json:"short_name,omitempty" binding:"omitempty,min=3,max=32"`
+    Title          string     `json:"title,omitempty"`
+    // Host puts the link on a registered custom domain.
+    Host           string     `json:"host,omitempty"`
+    Tags           []string   `json:"tags,omitempty"`
+    Preview        bool       `json:"preview,omitempty"`
+    Expires_at     *time.Time `json:"expires_at,omitempty"`
+    Expired_action string     `json:"expired_action,omitempty"`
+    Expired_url    string     `json:"expired_url,omitempty"`
+    Starts_at      *time.Time `json:"starts_at,omitempty"`
+    Schedule       *Schedule  `json:"schedule,omitempty"`
+    Inactive_action string    `json:"inactive_action,omitempty"`
+    Inactive_url   string     `json:"inactive_url,omitempty"`
+    Max_clicks     *int       `json:"max_clicks,omitempty"`
+    Redirect_type  int        `json:"redirect_type,omitempty"`
+    // Password protects the link; on PUT omit it to keep the current one
+    // or send "" to remove it.
+    Password       *string    `json:"password,omitempty"`
+    Query_passthrough bool    `json:"query_passthrough,omitempty"`
+    Query_precedence  string  `json:"query_precedence,omitempty"`
+    Utm_source        string  `json:"utm_source,omitempty"`
+    Utm_medium        string  `json:"utm_medium,omitempty"`
+    Utm_campaign      string  `json:"utm_campaign,omitempty"`
+    Targets           []TargetRule `json:"targets,omitempty"`
+    Variants          []Variant    `json:"variants,omitempty"`
+    Sticky_variants   bool         `json:"sticky_variants,omitempty"`
+    Geo_targets       map[string]string `json:"geo_targets,omitempty"`
 }
 
 
@@ -26,20 +170,175 @@ func (lr *LinkRequest) Validate() map[string]string {
     errors := make(map[string]string)
     if lr.Original_url == "" {
         errors["original_url"] = "обязательное поле"
-    } else {
-        u, err := url.ParseRequestURI(lr.Original_url)
-        if err != nil || u.Scheme == "" || u.Host == "" {
-            errors["original_url"] = "некорректный URL"
-        }
+    } else if !isValidURL(lr.Original_url) {
+        errors["original_url"] = "некорректный URL"
     }
     if lr.Short_name != "" {
         if len(lr.Short_name) < 3 || len(lr.Short_name) > 32 {
             errors["short_name"] = "длина должна быть от 3 до 32 символов"
-        }
-        matched, _ := regexp.MatchString("^[a-zA-Z0-9_-]+$", lr.Short_name)
-        if !matched {
+        } else if !shortNamePattern.MatchString(lr.Short_name) {
             errors["short_name"] = "может содержать только буквы, цифры, дефисы и подчеркивания"
         }
     }
+    switch lr.Expired_action {
+    case "", ExpiredActionGone, ExpiredActionPage:
+    case ExpiredActionFallback:
+        if lr.Expired_url == "" {
+            errors["expired_url"] = "обязательное поле"
+        }
+    default:
+        errors["expired_action"] = "допустимые значения: gone, fallback, page"
+    }
+    if lr.Expired_url != "" && !isValidURL(lr.Expired_url) {
+        errors["expired_url"] = "некорректный URL"
+    }
+    if utf8.RuneCountInString(lr.Title) > 255 {
+        errors["title"] = "длина не должна превышать 255 символов"
+    }
+    if len(lr.Tags) > 20 {
+        errors["tags"] = "не более 20 тегов"
+    }
+    tags := make(map[string]bool, len(lr.Tags))
+    for i, tag := range lr.Tags {
+        field := fmt.Sprintf("tags[%d]", i)
+        if matched, _ := regexp.MatchString(`^[\p{L}\p{N}_-]{1,32}$`, tag); !matched {
+            errors[field] = "от 1 до 32 букв, цифр, дефисов и подчеркиваний"
+        } else if tags[strings.ToLower(tag)] {
+            errors[field] = "уже существует"
+        }
+        tags[strings.ToLower(tag)] = true
+    }
+    if lr.Starts_at != nil && lr.Expires_at != nil && !lr.Starts_at.Before(*lr.Expires_at) {
+        errors["starts_at"] = "должно быть раньше expires_at"
+    }
+    if lr.Schedule != nil {
+        for field, message := range lr.Schedule.Validate() {
+            errors[field] = message
+        }
+    }
+    switch lr.Inactive_action {
+    case "", InactiveActionNotFound:
+    case InactiveActionFallback:
+        if lr.Inactive_url == "" {
+            errors["inactive_url"] = "обязательное поле"
+        }
+    default:
+        errors["inactive_action"] = "допустимые значения: not_found, fallback"
+    }
+    if lr.Inactive_url != "" && !isValidURL(lr.Inactive_url) {
+        errors["inactive_url"] = "некорректный URL"
+    }
+    if lr.Max_clicks != nil && *lr.Max_clicks < 1 {
+        errors["max_clicks"] = "должно быть положительным числом"
+    }
+    if lr.Password != nil && *lr.Password != "" && (len(*lr.Password) < 4 || len(*lr.Password) > 72) {
+        errors["password"] = "длина должна быть от 4 до 72 символов"
+    }
+    switch lr.Redirect_type {
+    case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+    default:
+        errors["redirect_type"] = "допустимые значения: 301, 302, 307, 308"
+    }
+    switch lr.Query_precedence {
+    case "", QueryPrecedenceDestination, QueryPrecedenceRequest:
+    default:
+        errors["query_precedence"] = "допустимые значения: destination, request"
+    }
+    for field, value := range map[string]string{
+        "utm_source":   lr.Utm_source,
+        "utm_medium":   lr.Utm_medium,
+        "utm_campaign": lr.Utm_campaign,
+    } {
+        if len(value) > 255 {
+            errors[field] = "длина не должна превышать 255 символов"
+        }
+    }
+    for i, rule := range lr.Targets {
+        field := fmt.Sprintf("targets[%d]", i)
+        switch rule.Match {
+        case TargetIOS, TargetAndroid, TargetWindows, TargetMacOS, TargetLinux, TargetMobile, TargetDesktop:
+        default:
+            errors[field+".match"] = "допустимые значения: ios, android, windows, macos, linux, mobile, desktop"
+        }
+        if !isValidURL(rule.Url) {
+            errors[field+".url"] = "некорректный URL"
+        }
+    }
+    names := make(map[string]bool, len(lr.Variants))
+    totalWeight := 0
+    for i, variant := range lr.Variants {
+        field := fmt.Sprintf("variants[%d]", i)
+        if matched, _ := regexp.MatchString("^[a-zA-Z0-9_-]{1,32}$", variant.Name); !matched {
+            errors[field+".name"] = "от 1 до 32 букв, цифр, дефисов и подчеркиваний"
+        } else if names[variant.Name] {
+            errors[field+".name"] = "уже существует"
+        }
+        names[variant.Name] = true
+        if !isValidURL(variant.Url) {
+            errors[field+".url"] = "некорректный URL"
+        }
+        if variant.Weight < 0 {
+            errors[field+".weight"] = "не может быть отрицательным"
+        }
+        totalWeight += variant.Weight
+    }
+    if len(lr.Variants) > 0 && totalWeight == 0 {
+        errors["variants"] = "хотя бы один вариант должен иметь положительный вес"
+    }
+    for country, target := range lr.Geo_targets {
+        field := fmt.Sprintf("geo_targets[%s]", country)
+        if matched, _ := regexp.MatchString("^[A-Z]{2}$", country); !matched {
+            errors[field] = "код страны должен состоять из двух заглавных латинских букв"
+        } else if !isValidURL(target) {
+            errors[field] = "некорректный URL"
+        }
+    }
     return errors
-}
\ No newline at end of file
+}
+
+type DomainRequest struct {
+    Host string `json:"host"`
+}
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Validate expects a bare lower-case host name: no scheme, port or path.
+func (dr *DomainRequest) Validate() map[string]string {
+    errors := make(map[string]string)
+    switch {
+    case dr.Host == "":
+        errors["host"] = "обязательное поле"
+    case len(dr.Host) > 255 || !hostnamePattern.MatchString(dr.Host):
+        errors["host"] = "некорректное имя хоста"
+    }
+    return errors
+}
+
+type ReservedNameRequest struct {
+    Name string `json:"name"`
+}
+
+// Validate expects a lower-case name that a link could otherwise take.
+func (rr *ReservedNameRequest) Validate() map[string]string {
+    errors := make(map[string]string)
+    switch {
+    case rr.Name == "":
+        errors["name"] = "обязательное поле"
+    case !IsValidShortName(rr.Name):
+        errors["name"] = "от 3 до 32 букв, цифр, дефисов и подчеркиваний"
+    }
+    return errors
+}
+
+var shortNamePattern = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
+// IsValidShortName applies the short_name rules of Validate to a generated
+// name: 3 to 32 letters, digits, hyphens and underscores.
+func IsValidShortName(name string) bool {
+    return len(name) >= 3 && len(name) <= 32 && shortNamePattern.MatchString(name)
+}
+
+func isValidURL(urlStr string) bool {
+    u, err := url.ParseRequestURI(urlStr)
+    return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -1,10 +1,175 @@
 package dto
 
+import "time"
 
 //от меня
 type LinkResponce struct{
 	Id 				int		`json:"id"`
 	Original_url 	string	`json:"original_url"`
 	Short_name 		string	`json:"short_name"`
+	// Short_url is the address to share, built from Host or the public base
+	// URL whenever a link is returned; it is not stored.
 	Short_url 		string	`json:"short_url"`
+	// Host is the custom domain the link lives on, "" for the default one.
+	// Short names are unique per host.
+	Host			string	`json:"host,omitempty"`
+	Title			string	`json:"title,omitempty"`
+	// Tags are lower-cased labels for finding links in the admin UI.
+	Tags			[]string	`json:"tags,omitempty"`
+	// Original_domain is the host of Original_url, lower-cased and without
+	// "www.". It is derived on save so that links can be filtered by it.
+	Original_domain	string	`json:"original_domain,omitempty"`
+	// Created_at is set by the repository on create; nil for links older than the column.
+	Created_at		*time.Time	`json:"created_at,omitempty"`
+	// Preview shows an interstitial page with a Continue button instead of
+	// redirecting. Appending "+" to the code does the same for any link.
+	Preview			bool	`json:"preview"`
+	Expires_at		*time.Time	`json:"expires_at"`
+	Expired_action	string	`json:"expired_action"`
+	Expired_url		string	`json:"expired_url,omitempty"`
+	// Starts_at and Schedule limit when the link redirects; outside of them
+	// Inactive_action decides what the visitor gets.
+	Starts_at		*time.Time	`json:"starts_at"`
+	Schedule		*Schedule	`json:"schedule,omitempty"`
+	Inactive_action	string	`json:"inactive_action"`
+	Inactive_url	string	`json:"inactive_url,omitempty"`
+	// Max_clicks caps successful redirects; nil means unlimited. Visits
+	// past the limit get 403, unlike the 410 of an expired link.
+	Max_clicks		*int	`json:"max_clicks"`
+	Clicks			int		`json:"clicks"`
+	// Redirect_type is the HTTP status Redirect answers with: 301, 302, 307 or 308.
+	Redirect_type	int		`json:"redirect_type"`
+	// Password_hash is a bcrypt hash and never leaves the service. On update
+	// nil keeps the stored hash and an empty string removes the password.
+	Password_hash	*string	`json:"-"`
+	// Query_passthrough forwards the query string of /r/:code to the
+	// destination; Query_precedence says which side wins on a clash.
+	Query_passthrough	bool	`json:"query_passthrough"`
+	Query_precedence	string	`json:"query_precedence"`
+	// The UTM template is added to every redirect. Values may use {short_name}.
+	Utm_source		string	`json:"utm_source,omitempty"`
+	Utm_medium		string	`json:"utm_medium,omitempty"`
+	Utm_campaign	string	`json:"utm_campaign,omitempty"`
+	// Targets send matching devices elsewhere; the first rule that matches
+	// the visitor's User-Agent wins, otherwise Original_url is used.
+	Targets			[]TargetRule	`json:"targets,omitempty"`
+	// Variants split traffic between destinations by weight when no
+	// targeting rule matched. Sticky_variants pins a visitor to a variant
+	// with a cookie.
+	Variants		[]Variant	`json:"variants,omitempty"`
+	// Geo_targets maps a visitor's country code to a destination. It is
+	// checked after device targets and before variants.
+	Geo_targets		map[string]string	`json:"geo_targets,omitempty"`
+	Sticky_variants	bool	`json:"sticky_variants"`
+	// Expired and Active are computed when the link is served, they are not stored.
+	Expired			bool	`json:"expired"`
+	Active			bool	`json:"active"`
+}
+
+// Domain is a custom domain links can be created on. Its DNS must point
+// at this service.
+type Domain struct {
+	Id			int		`json:"id"`
+	Host		string	`json:"host"`
+	Created_at	time.Time	`json:"created_at"`
+}
+
+// Sources of a reserved short name.
+const (
+	ReservedBuiltin = "builtin"
+	ReservedFile    = "file"
+	ReservedAPI     = "api"
+)
+
+// ReservedName is a short name no link may take. Only names added through
+// the API have a Created_at and can be deleted.
+type ReservedName struct {
+	Name		string		`json:"name"`
+	Source		string		`json:"source"`
+	Created_at	*time.Time	`json:"created_at,omitempty"`
+}
+
+// TargetRule redirects visitors whose device matches Match to Url.
+type TargetRule struct {
+	Match	string	`json:"match"`
+	Url		string	`json:"url"`
+}
+
+// Variant is one destination of an A/B split. A visit picks it with
+// probability Weight divided by the sum of all weights.
+type Variant struct {
+	Name	string	`json:"name"`
+	Url		string	`json:"url"`
+	Weight	int		`json:"weight"`
+}
+
+// LinkStats is returned by GET /api/links/:id/stats. Clicks and Variants
+// cover the link's whole life, the VisitStats fields only From to To.
+type LinkStats struct {
+	Link_id		int		`json:"link_id"`
+	Clicks		int		`json:"clicks"`
+	From		time.Time	`json:"from"`
+	To			time.Time	`json:"to"`
+	Interval	string	`json:"interval"`
+	VisitStats
+	Variants	[]VariantStats	`json:"variants"`
+}
+
+// VisitPage is one page of GET /api/link_visits in cursor mode. Next_cursor
+// is sent back as after_id for the following page; it is null on the last.
+type VisitPage struct {
+	Data		[]*Visit	`json:"data"`
+	Next_cursor	*int	`json:"next_cursor"`
+}
+
+// VisitStats aggregates the visits of a link over a time range. A click is
+// a visit that was served (status 2xx or 3xx). The timeline, top referrers
+// and top user agents count clicks; Statuses counts every visit, refusals
+// included.
+type VisitStats struct {
+	Total_clicks	int	`json:"total_clicks"`
+	// Unique_visitors counts distinct IP and User-Agent pairs among clicks.
+	Unique_visitors	int	`json:"unique_visitors"`
+	Timeline		[]TimelinePoint	`json:"timeline"`
+	Top_referrers	[]RefererCount	`json:"top_referrers"`
+	Top_user_agents	[]UserAgentCount	`json:"top_user_agents"`
+	Statuses		map[int]int	`json:"statuses"`
+}
+
+// TimelinePoint is the number of clicks in the day or hour starting at Time (UTC).
+type TimelinePoint struct {
+	Time	time.Time	`json:"time"`
+	Clicks	int		`json:"clicks"`
+}
+
+// UserAgentCount is one row of the top user-agent families.
+type UserAgentCount struct {
+	Family	string	`json:"family"`
+	Visits	int		`json:"visits"`
+}
+
+// VariantStats counts the redirects that were served through one variant.
+type VariantStats struct {
+	Variant
+	Clicks	int	`json:"clicks"`
+}
+
+// RefererCount is one row of GET /api/links/:id/referrers.
+type RefererCount struct {
+	Domain	string	`json:"domain"`
+	Visits	int		`json:"visits"`
+}
+
+// IsExpired reports whether the link's expires_at has passed at now.
+func (l *LinkResponce) IsExpired(now time.Time) bool {
+	return l.Expires_at != nil && !now.Before(*l.Expires_at)
+}
+
+// IsActive reports whether the link has reached starts_at and, if it has a
+// schedule, whether now is inside the window. Expiry is checked separately.
+func (l *LinkResponce) IsActive(now time.Time) bool {
+	if l.Starts_at != nil && now.Before(*l.Starts_at) {
+		return false
+	}
+	return l.Schedule == nil || l.Schedule.Contains(now)
 }
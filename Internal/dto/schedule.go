@@ -0,0 +1,113 @@
+package dto
+
+import (
+	"fmt"
+	"time"
+
+	// Embedded so that timezone names resolve in images without tzdata.
+	_ "time/tzdata"
+)
+
+// What Redirect does while a link is outside starts_at or its schedule.
+const (
+	InactiveActionNotFound = "not_found" // 404 with a small HTML page
+	InactiveActionFallback = "fallback"  // redirect to inactive_url instead
+)
+
+// Schedule is a recurring activation window, for example weekdays from 09:00
+// to 18:00 in Europe/Moscow. When To is earlier than From the window runs
+// past midnight and belongs to the day it starts on.
+type Schedule struct {
+	Days     []string `json:"days"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Timezone string   `json:"timezone"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// clock parses "HH:MM" into minutes since midnight.
+func clock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != len("15:04") {
+		return 0, fmt.Errorf("time must be HH:MM: %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate returns the field errors of the schedule, keyed like the other
+// LinkRequest errors.
+func (s *Schedule) Validate() map[string]string {
+	errors := make(map[string]string)
+	if len(s.Days) == 0 {
+		errors["schedule.days"] = "обязательное поле"
+	}
+	for _, day := range s.Days {
+		if _, ok := weekdays[day]; !ok {
+			errors["schedule.days"] = "допустимые значения: mon, tue, wed, thu, fri, sat, sun"
+		}
+	}
+	from, err := clock(s.From)
+	if err != nil {
+		errors["schedule.from"] = "время в формате ЧЧ:ММ"
+	}
+	to, err := clock(s.To)
+	if err != nil {
+		errors["schedule.to"] = "время в формате ЧЧ:ММ"
+	}
+	if _, ok := errors["schedule.from"]; !ok && from == to {
+		if _, ok := errors["schedule.to"]; !ok {
+			errors["schedule.to"] = "должно отличаться от from"
+		}
+	}
+	if s.Timezone == "" {
+		errors["schedule.timezone"] = "обязательное поле"
+	} else if _, err := time.LoadLocation(s.Timezone); err != nil {
+		errors["schedule.timezone"] = "неизвестный часовой пояс"
+	}
+	return errors
+}
+
+// Contains reports whether now falls inside the window. An invalid schedule
+// contains nothing.
+func (s *Schedule) Contains(now time.Time) bool {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	from, err := clock(s.From)
+	if err != nil {
+		return false
+	}
+	to, err := clock(s.To)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if from < to {
+		return minute >= from && minute < to && s.on(local.Weekday())
+	}
+	// Overnight: the evening part belongs to today, the morning part to yesterday.
+	if minute >= from {
+		return s.on(local.Weekday())
+	}
+	return minute < to && s.on((local.Weekday()+6)%7)
+}
+
+func (s *Schedule) on(day time.Weekday) bool {
+	for _, d := range s.Days {
+		if weekdays[d] == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package dto_test
+
+import (
+	"testing"
+	"time"
+
+	"go-project-278/Internal/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleContains(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	workdays := &dto.Schedule{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "09:00", To: "18:00", Timezone: "Europe/Moscow"}
+	overnight := &dto.Schedule{Days: []string{"fri"}, From: "22:00", To: "02:00", Timezone: "Europe/Moscow"}
+
+	tests := []struct {
+		name     string
+		schedule *dto.Schedule
+		at       time.Time
+		want     bool
+	}{
+		{"weekday inside", workdays, time.Date(2025, 6, 2, 9, 0, 0, 0, moscow), true},
+		{"weekday end is exclusive", workdays, time.Date(2025, 6, 2, 18, 0, 0, 0, moscow), false},
+		{"weekend", workdays, time.Date(2025, 6, 7, 12, 0, 0, 0, moscow), false},
+		{"other timezone", workdays, time.Date(2025, 6, 2, 6, 30, 0, 0, time.UTC), true},
+		{"overnight evening", overnight, time.Date(2025, 6, 6, 23, 0, 0, 0, moscow), true},
+		{"overnight after midnight", overnight, time.Date(2025, 6, 7, 1, 59, 0, 0, moscow), true},
+		{"overnight previous day", overnight, time.Date(2025, 6, 6, 1, 0, 0, 0, moscow), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.schedule.Contains(tt.at), tt.name)
+	}
+}
+
+func TestLinkIsActive(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Minute)
+	link := dto.LinkResponce{Starts_at: &later}
+	assert.False(t, link.IsActive(now))
+	assert.True(t, link.IsActive(later))
+	assert.True(t, (&dto.LinkResponce{}).IsActive(now))
+}
@@ -0,0 +1,189 @@
+// Package geoip resolves IP addresses to ISO 3166-1 alpha-2 country codes
+// from a local CSV file of address ranges, so redirects never wait on an
+// online service.
+//
+// Each line of the file holds the first address of a range, the last address
+// and the country code, for example:
+//
+//	1.0.0.0,1.0.0.255,AU
+//	2001:200::,2001:200:ffff:ffff:ffff:ffff:ffff:ffff,JP
+//
+// Fields may be quoted and extra columns are ignored, which covers the free
+// DB-IP and IP2Location "country lite" CSV downloads. Lines starting with #
+// and a header line are skipped. Only this CSV range format is supported;
+// binary databases such as MaxMind's .mmdb have to be exported to it first.
+package geoip
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ipRange struct {
+	first, last netip.Addr
+	country     string
+}
+
+// Table is one loaded copy of the database. It is immutable.
+type Table struct {
+	ranges []ipRange
+}
+
+// Parse reads a range CSV. The order of the lines does not matter, but
+// ranges must not overlap: a file with overlapping ranges is rejected, as
+// the country of an address in both would depend on the order.
+func Parse(r io.Reader) (*Table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var ranges []ipRange
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("geoip line %d: want first,last,country", line)
+		}
+		first, err := netip.ParseAddr(record[0])
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		last, err := netip.ParseAddr(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("geoip line %d: %w", line, err)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return nil, fmt.Errorf("geoip line %d: invalid range %s-%s", line, first, last)
+		}
+		country := strings.ToUpper(strings.TrimSpace(record[2]))
+		if len(country) != 2 {
+			return nil, fmt.Errorf("geoip line %d: invalid country %q", line, record[2])
+		}
+		ranges = append(ranges, ipRange{first: first, last: last, country: country})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+	for i := 1; i < len(ranges); i++ {
+		prev, next := ranges[i-1], ranges[i]
+		if !prev.last.Less(next.first) {
+			return nil, fmt.Errorf("geoip: range %s-%s overlaps %s-%s", prev.first, prev.last, next.first, next.last)
+		}
+	}
+	return &Table{ranges: ranges}, nil
+}
+
+// Lookup returns the country code for ip, or "" when it is not covered.
+func (t *Table) Lookup(ip netip.Addr) string {
+	ip = ip.Unmap()
+	// The last range starting at or before ip is the only one that can hold it.
+	i := sort.Search(len(t.ranges), func(i int) bool { return ip.Less(t.ranges[i].first) }) - 1
+	if i < 0 || t.ranges[i].last.Less(ip) {
+		return ""
+	}
+	return t.ranges[i].country
+}
+
+// Resolver serves lookups from the file at Path and can swap in a new copy
+// of it without blocking lookups. A nil *Resolver resolves nothing.
+type Resolver struct {
+	path    string
+	table   atomic.Pointer[Table]
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// Open loads the database at path.
+func Open(path string) (*Resolver, error) {
+	r := &Resolver{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Country returns the country code for a textual IP address, or "" when the
+// address is invalid or unknown.
+func (r *Resolver) Country(ip string) string {
+	if r == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	return r.table.Load().Lookup(addr)
+}
+
+// Reload reads the file again. On error the previous copy stays in use.
+func (r *Resolver) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("open geoip database: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("open geoip database: %w", err)
+	}
+	// Remember the version even if it is broken, so Watch reports it once.
+	r.modTime = info.ModTime()
+	table, err := Parse(f)
+	if err != nil {
+		return err
+	}
+	r.table.Store(table)
+	return nil
+}
+
+// Watch reloads the file whenever its modification time changes, checking
+// every interval until ctx is done. Replace the file atomically (write a
+// temporary file, then rename) so a half-written copy is never read.
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Printf("geoip reload: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Resolver) changed() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime)
+}
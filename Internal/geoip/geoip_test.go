@@ -0,0 +1,84 @@
+package geoip_test
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-project-278/Internal/geoip"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `# first,last,country
+"ip_start","ip_end","country"
+"5.0.0.0","5.255.255.255","DE"
+1.0.0.0,1.0.0.255,AU
+2001:200::,2001:200:ffff:ffff:ffff:ffff:ffff:ffff,JP
+`
+
+func TestParseAndLookup(t *testing.T) {
+	table, err := geoip.Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"1.0.0.0":          "AU",
+		"1.0.0.255":        "AU",
+		"1.0.1.0":          "",
+		"5.9.10.11":        "DE",
+		"::ffff:5.9.10.11": "DE",
+		"0.0.0.1":          "",
+		"2001:200::1":      "JP",
+		"2001:201::1":      "",
+	}
+	for ip, want := range tests {
+		assert.Equal(t, want, table.Lookup(netip.MustParseAddr(ip)), ip)
+	}
+}
+
+func TestParseRejectsBadRanges(t *testing.T) {
+	for _, input := range []string{
+		"1.0.0.255,1.0.0.0,AU\n",
+		"1.0.0.0,2001:200::,AU\n",
+		"1.0.0.0,1.0.0.255,AUS\n",
+		"1.0.0.0,1.0.0.255,AU\nnot-an-ip,1.0.0.0,AU\n",
+		// Overlapping ranges, in either order.
+		"1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.255,NZ\n",
+		"1.0.0.128,1.0.1.255,NZ\n1.0.0.0,1.0.0.255,AU\n",
+		"1.0.0.0,1.0.0.255,AU\n1.0.0.255,1.0.0.255,NZ\n",
+	} {
+		_, err := geoip.Parse(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestResolverReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	require.NoError(t, os.WriteFile(path, []byte("1.0.0.0,1.0.0.255,AU\n"), 0o644))
+	r, err := geoip.Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, "AU", r.Country("1.0.0.1"))
+	assert.Equal(t, "", r.Country("not an ip"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("1.0.0.0,1.0.0.255,NZ\n"), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Eventually(t, func() bool { return r.Country("1.0.0.1") == "NZ" }, 2*time.Second, 10*time.Millisecond)
+
+	// A broken file keeps the previous copy in use.
+	require.NoError(t, os.WriteFile(path, []byte("garbage,1.0.0.0,NZ\n1.0.0.0\n"), 0o644))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "NZ", r.Country("1.0.0.1"))
+
+	var nilResolver *geoip.Resolver
+	assert.Equal(t, "", nilResolver.Country("1.0.0.1"))
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"go-project-278/Internal/dto"
+	"net/url"
+	"strings"
+)
+
+// queryParam is one key=value pair of a query string. The pair is kept as it
+// was written so that parameters nobody touches are passed on byte for byte.
+type queryParam struct {
+	key string
+	raw string
+}
+
+// splitQuery breaks a raw query string into its pairs, keeping their order.
+func splitQuery(rawQuery string) []queryParam {
+	var params []queryParam
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		params = append(params, queryParam{key: key, raw: raw})
+	}
+	return params
+}
+
+func joinQuery(params []queryParam) string {
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+// mergeQuery lays extra over base. A key present in both is taken from the
+// winning side: all of its values, at the position it had in base. Keys only
+// in extra are appended in their original order.
+func mergeQuery(base, extra []queryParam, extraWins bool) []queryParam {
+	inBase := make(map[string]bool, len(base))
+	for _, p := range base {
+		inBase[p.key] = true
+	}
+	byKey := make(map[string][]queryParam, len(extra))
+	for _, p := range extra {
+		byKey[p.key] = append(byKey[p.key], p)
+	}
+
+	merged := make([]queryParam, 0, len(base)+len(extra))
+	replaced := make(map[string]bool)
+	for _, p := range base {
+		override, ok := byKey[p.key]
+		if !extraWins || !ok {
+			merged = append(merged, p)
+			continue
+		}
+		if !replaced[p.key] {
+			merged = append(merged, override...)
+			replaced[p.key] = true
+		}
+	}
+	for _, p := range extra {
+		if !inBase[p.key] {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// utmParams renders the link's UTM template. Empty fields are left out.
+func utmParams(link *dto.LinkResponce) []queryParam {
+	var params []queryParam
+	for _, field := range []struct{ key, value string }{
+		{"utm_source", link.Utm_source},
+		{"utm_medium", link.Utm_medium},
+		{"utm_campaign", link.Utm_campaign},
+	} {
+		if field.value == "" {
+			continue
+		}
+		value := strings.ReplaceAll(field.value, "{short_name}", link.Short_name)
+		params = append(params, queryParam{key: field.key, raw: field.key + "=" + url.QueryEscape(value)})
+	}
+	return params
+}
+
+// destinationURL is where Redirect sends the visitor, starting from target:
+// original_url or the URL of a matched targeting rule. The link's UTM
+// template overrides the same parameters in target; with query_passthrough
+// the incoming query is then merged in, and query_precedence decides clashes.
+// The fragment of target is kept.
+func destinationURL(link *dto.LinkResponce, target, incomingQuery string) string {
+	utm := utmParams(link)
+	incoming := splitQuery(incomingQuery)
+	if len(utm) == 0 && (!link.Query_passthrough || len(incoming) == 0) {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	params := mergeQuery(splitQuery(u.RawQuery), utm, true)
+	if link.Query_passthrough {
+		params = mergeQuery(params, incoming, link.Query_precedence == dto.QueryPrecedenceRequest)
+	}
+	u.RawQuery = joinQuery(params)
+	u.ForceQuery = false
+	return u.String()
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"go-project-278/Internal/dto"
+	"go-project-278/Internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDomains lists the registered custom domains by host name.
+func (a *App) ListDomains(c *gin.Context) {
+	domains, err := a.Repo.ListDomains(a.Ctx)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	if domains == nil {
+		domains = []dto.Domain{}
+	}
+	c.Header("Content-Range", contentRange("domains", 0, len(domains), len(domains)))
+	c.JSON(http.StatusOK, domains)
+}
+
+// CreateDomain registers a custom domain links can be put on. Its DNS has
+// to point at this service for the links to resolve.
+func (a *App) CreateDomain(c *gin.Context) {
+	var request dto.DomainRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondWithBadRequest(c, "invalid request")
+		return
+	}
+	request.Host = strings.ToLower(strings.TrimSpace(request.Host))
+	if validationErrors := request.Validate(); len(validationErrors) > 0 {
+		respondWithValidationErrors(c, validationErrors)
+		return
+	}
+	domain, err := a.Repo.CreateDomain(a.Ctx, request.Host)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithValidationError(c, "host", "уже существует")
+		return
+	}
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, domain)
+}
+
+// DeleteDomain removes a custom domain no link uses any more.
+func (a *App) DeleteDomain(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondWithBadRequest(c, "invalid id")
+		return
+	}
+	err = a.Repo.DeleteDomain(a.Ctx, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "domain is used by links"})
+	case err != nil:
+		respondWithRepoError(c, err)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// checkLinkHost normalises the host a link is put on and makes sure it is
+// a registered custom domain. It reports false once it has responded.
+func (a *App) checkLinkHost(c *gin.Context, request *dto.LinkRequest) bool {
+	request.Host = strings.ToLower(strings.TrimSpace(request.Host))
+	if request.Host == "" {
+		return true
+	}
+	_, err := a.Repo.GetDomainByHost(a.Ctx, request.Host)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithValidationError(c, "host", "домен не зарегистрирован")
+		return false
+	}
+	if err != nil {
+		respondWithRepoError(c, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-project-278/Internal/dto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The list endpoints speak react-admin's simple REST dialect:
+//
+//	range=[0,24]   sort=["created_at","DESC"]   filter={"link_id":3}
+//
+// getMany asks for records by id with filter={"id":[1,2,3]}, and
+// getManyReference filters on the reference target along with the usual
+// sort and range.
+
+// parseRange reads range=[start,end]; both ends are inclusive, so the page
+// holds end-start+1 items. ok is false when the parameter is
+// absent; problem is set when it is malformed.
+func parseRange(c *gin.Context) (start, end int, ok bool, problem string) {
+	rangeParam := c.Query("range")
+	if rangeParam == "" {
+		return 0, 0, false, ""
+	}
+	parts := strings.Split(strings.Trim(rangeParam, "[]"), ",")
+	if len(parts) != 2 {
+		return 0, 0, true, "range must be in format [start,end]"
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	end, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		return 0, 0, true, "range values must be integers"
+	}
+	if start < 0 || end < 0 || start > end {
+		return 0, 0, true, "invalid range values"
+	}
+	return start, end, true, ""
+}
+
+// contentRange renders the Content-Range header for count items returned
+// from position start out of total. An empty page has no range to report.
+func contentRange(resource string, start, count, total int) string {
+	if count == 0 {
+		return fmt.Sprintf("%s */%d", resource, total)
+	}
+	return fmt.Sprintf("%s %d-%d/%d", resource, start, start+count-1, total)
+}
+
+const (
+	defaultCursorLimit = 100
+	maxCursorLimit     = 1000
+)
+
+// parseCursor reads after_id (0 when absent) and limit for cursor paging.
+func parseCursor(c *gin.Context) (afterID, limit int, problem string) {
+	if raw := c.Query("after_id"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return 0, 0, "after_id must be a positive integer"
+		}
+		afterID = n
+	}
+	limit = defaultCursorLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCursorLimit {
+			return 0, 0, fmt.Sprintf("limit must be between 1 and %d", maxCursorLimit)
+		}
+		limit = n
+	}
+	return afterID, limit, ""
+}
+
+// parseSort reads sort=["column","ASC|DESC"], allowing only columns.
+func parseSort(c *gin.Context, columns []string) (column string, desc bool, problem string) {
+	sortParam := c.Query("sort")
+	if sortParam == "" {
+		return "", false, ""
+	}
+	var parts []string
+	if err := json.Unmarshal([]byte(sortParam), &parts); err != nil || len(parts) != 2 {
+		return "", false, `sort must be in format ["column","ASC|DESC"]`
+	}
+	if !slices.Contains(columns, parts[0]) {
+		return "", false, "sort column must be one of: " + strings.Join(columns, ", ")
+	}
+	switch strings.ToUpper(parts[1]) {
+	case "ASC":
+	case "DESC":
+		desc = true
+	default:
+		return "", false, "sort order must be ASC or DESC"
+	}
+	return parts[0], desc, ""
+}
+
+// decodeFilter decodes the filter parameter into dst. Keys dst does not
+// know are ignored: the admin frontend sends whatever reference target a
+// view is configured with, and a list it cannot narrow is better than an
+// error page.
+func decodeFilter(c *gin.Context, dst any) string {
+	filterParam := c.Query("filter")
+	if filterParam == "" {
+		return ""
+	}
+	if err := json.Unmarshal([]byte(filterParam), dst); err != nil {
+		return "invalid filter: " + err.Error()
+	}
+	return ""
+}
+
+type linkFilterParams struct {
+	ID           []int      `json:"id"`
+	Q            string     `json:"q"`
+	Domain       string     `json:"domain"`
+	Tag          string     `json:"tag"`
+	CreatedAtGte *time.Time `json:"created_at_gte"`
+	CreatedAtLte *time.Time `json:"created_at_lte"`
+}
+
+// parseLinkFilter normalises domain and tag the way they are stored, so
+// that "WWW.Example.com" finds links to example.com.
+func parseLinkFilter(c *gin.Context) (dto.LinkFilter, string) {
+	var params linkFilterParams
+	if problem := decodeFilter(c, &params); problem != "" {
+		return dto.LinkFilter{}, problem
+	}
+	filter := dto.LinkFilter{
+		IDs:         params.ID,
+		Q:           strings.TrimSpace(params.Q),
+		Tag:         strings.ToLower(params.Tag),
+		CreatedFrom: params.CreatedAtGte,
+		CreatedTo:   params.CreatedAtLte,
+	}
+	if params.Domain != "" {
+		filter.Domain = normalizeHost(params.Domain)
+	}
+	return filter, ""
+}
+
+type visitFilterParams struct {
+	ID           []int      `json:"id"`
+	LinkID       *int       `json:"link_id"`
+	IP           string     `json:"ip"`
+	Status       *int       `json:"status"`
+	CreatedAtGte *time.Time `json:"created_at_gte"`
+	CreatedAtLte *time.Time `json:"created_at_lte"`
+}
+
+func parseVisitFilter(c *gin.Context) (dto.VisitFilter, string) {
+	var params visitFilterParams
+	if problem := decodeFilter(c, &params); problem != "" {
+		return dto.VisitFilter{}, problem
+	}
+	return dto.VisitFilter{
+		IDs:         params.ID,
+		LinkID:      params.LinkID,
+		IP:          params.IP,
+		Status:      params.Status,
+		CreatedFrom: params.CreatedAtGte,
+		CreatedTo:   params.CreatedAtLte,
+	}, ""
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"go-project-278/Internal/dto"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pages served to visitors of /r/:code instead of a redirect. They are kept
+// deliberately small and self-contained: no assets, no scripts.
+
+const pageLayout = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{template "title" .}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+h1 { font-size: 1.4rem; }
+.muted { color: #666; }
+</style>
+</head>
+<body>
+{{template "body" .}}
+</body>
+</html>`
+
+var expiredPage = newPage(`
+{{define "title"}}Ссылка больше не действует{{end}}
+{{define "body"}}
+<h1>Ссылка больше не действует</h1>
+<p>Срок действия короткой ссылки <strong>{{.Short_name}}</strong> истёк{{with .Expires_at}} {{.Format "02.01.2006 15:04 MST"}}{{end}}.</p>
+{{end}}`)
+
+var inactivePage = newPage(`
+{{define "title"}}Ссылка сейчас не активна{{end}}
+{{define "body"}}
+<h1>Ссылка сейчас не активна</h1>
+{{with .Starts_at}}<p>Ссылка <strong>{{$.Short_name}}</strong> заработает {{.Format "02.01.2006 15:04 MST"}}.</p>
+{{else}}<p>Ссылка <strong>{{.Short_name}}</strong> работает только по расписанию.</p>{{end}}
+{{with .Schedule}}<p class="muted">{{range $i, $d := .Days}}{{if $i}}, {{end}}{{$d}}{{end}}, {{.From}}–{{.To}} ({{.Timezone}})</p>{{end}}
+{{end}}`)
+
+var previewPage = newPage(`
+{{define "title"}}{{with .Link.Title}}{{.}}{{else}}Переход по ссылке{{end}}{{end}}
+{{define "body"}}
+<h1>{{with .Link.Title}}{{.}}{{else}}Переход по ссылке{{end}}</h1>
+<p>Короткая ссылка <strong>{{.Link.Short_name}}</strong> ведёт на:</p>
+<p><code>{{.Destination}}</code></p>
+{{with .Link.Created_at}}<p class="muted">Создана {{.Format "02.01.2006"}}</p>{{end}}
+<p><a href="{{.Destination}}" rel="noopener noreferrer">Продолжить</a></p>
+{{end}}`)
+
+type previewData struct {
+	Link        *dto.LinkResponce
+	Destination string
+}
+
+var passwordPage = newPage(`
+{{define "title"}}Ссылка защищена паролем{{end}}
+{{define "body"}}
+<h1>Ссылка защищена паролем</h1>
+{{with .Error}}<p class="muted">{{.}}</p>{{end}}
+<form method="post">
+<p><input type="password" name="password" autocomplete="current-password" required autofocus></p>
+<p><button type="submit">Перейти</button></p>
+</form>
+{{end}}`)
+
+type passwordForm struct {
+	Link  *dto.LinkResponce
+	Error string
+}
+
+func newPage(body string) *template.Template {
+	return template.Must(template.Must(template.New("layout").Parse(pageLayout)).Parse(body))
+}
+
+func renderPage(c *gin.Context, status int, page *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
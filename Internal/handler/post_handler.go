@@ -2,28 +2,49 @@ package handler
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"go-project-278/Internal/dto"
+	"go-project-278/Internal/geoip"
 	"go-project-278/Internal/repository"
+	"go-project-278/Internal/shortcode"
+	"math"
 	"net/http"
-	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type App struct {
 	Ctx  context.Context
 	Repo repository.PostRepository
+	// Geo resolves visitors' countries; nil disables geo targeting.
+	Geo *geoip.Resolver
+	// Codes names links created without a short_name; nil means random
+	// base62 codes of shortcode.DefaultLength.
+	Codes shortcode.CodeGenerator
+	// BaseURL is the public address short_url is built on, such as
+	// "https://sho.rt"; "" takes the scheme and host of each API request.
+	BaseURL string
+	// ReservedNames are lower-case short names links may not take, on top
+	// of the built-in ones and those reserved through the API.
+	ReservedNames map[string]bool
+	// CaseInsensitive makes short names unique regardless of case: new
+	// names are stored in lower case and Redirect ignores case.
+	CaseInsensitive bool
+
+	passwordsOnce sync.Once
+	passwords     *passwordGuard
+}
+
+func (a *App) passwordGuard() *passwordGuard {
+	a.passwordsOnce.Do(func() { a.passwords = newPasswordGuard() })
+	return a.passwords
 }
 
 
@@ -35,22 +56,6 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
-func isValidURL(urlStr string) bool {
-	u, err := url.ParseRequestURI(urlStr)
-	return err == nil && u.Scheme != "" && u.Host != ""
-}
-
-func isValidShortName(shortName string) bool {
-	if shortName == "" {
-		return true  
-	}
-	if len(shortName) < 3 || len(shortName) > 32 {
-		return false
-	}
-	matched, _ := regexp.MatchString("^[a-zA-Z0-9_-]+$", shortName)
-	return matched
-}
-
 func respondWithValidationError(c *gin.Context, field, message string) {
 	c.JSON(http.StatusUnprocessableEntity, ValidationErrorResponse{
 		Errors: map[string]string{field: message},
@@ -69,6 +74,40 @@ func respondWithBadRequest(c *gin.Context, message string) {
 	})
 }
 
+// respondWithRepoError maps the repository's sentinel errors to HTTP statuses.
+// Anything unrecognised is reported as a plain 500.
+func respondWithRepoError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict"})
+	case errors.Is(err, repository.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service unavailable"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// respondWithShortNameTaken reports a short_name another link has: 409,
+// whether the check before saving or the unique index found it, with the
+// field error the admin form shows.
+func respondWithShortNameTaken(c *gin.Context) {
+	c.JSON(http.StatusConflict, ValidationErrorResponse{
+		Errors: map[string]string{"short_name": "уже существует"},
+	})
+}
+
+// respondWithSaveError reports a failed create or update. A conflict there can
+// only come from the unique short_name.
+func respondWithSaveError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithShortNameTaken(c)
+		return
+	}
+	respondWithRepoError(c, err)
+}
+
 func JSONValidationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
@@ -92,25 +131,102 @@ func JSONValidationMiddleware() gin.HandlerFunc {
 	}
 }
 
-func GenerateUniqueString() string {
-	return uuid.New().String()
+// newLink builds the stored link from a validated request.
+func newLink(request dto.LinkRequest, shortName string) dto.LinkResponce {
+	expiredAction := request.Expired_action
+	if expiredAction == "" {
+		expiredAction = dto.ExpiredActionGone
+	}
+	redirectType := request.Redirect_type
+	if redirectType == 0 {
+		redirectType = http.StatusFound
+	}
+	inactiveAction := request.Inactive_action
+	if inactiveAction == "" {
+		inactiveAction = dto.InactiveActionNotFound
+	}
+	queryPrecedence := request.Query_precedence
+	if queryPrecedence == "" {
+		queryPrecedence = dto.QueryPrecedenceDestination
+	}
+	return dto.LinkResponce{
+		Original_url:    request.Original_url,
+		Short_name:      shortName,
+		Host:            request.Host,
+		Title:           request.Title,
+		Tags:            normalizeTags(request.Tags),
+		Original_domain: linkDomain(request.Original_url),
+		Preview:         request.Preview,
+		Expires_at:      request.Expires_at,
+		Expired_action:  expiredAction,
+		Expired_url:     request.Expired_url,
+		Starts_at:       request.Starts_at,
+		Schedule:        request.Schedule,
+		Inactive_action: inactiveAction,
+		Inactive_url:    request.Inactive_url,
+		Max_clicks:      request.Max_clicks,
+		Redirect_type:   redirectType,
+
+		Query_passthrough: request.Query_passthrough,
+		Query_precedence:  queryPrecedence,
+		Utm_source:        request.Utm_source,
+		Utm_medium:        request.Utm_medium,
+		Utm_campaign:      request.Utm_campaign,
+		Targets:           request.Targets,
+		Variants:          request.Variants,
+		Sticky_variants:   request.Sticky_variants,
+		Geo_targets:       request.Geo_targets,
+	}
+}
+
+// normalizeTags lower-cases tags so that filtering by tag ignores case.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(tags))
+	for i, tag := range tags {
+		normalized[i] = strings.ToLower(tag)
+	}
+	return normalized
+}
+
+// redirectStatus is the status a link redirects with, 302 unless it says otherwise.
+func redirectStatus(link *dto.LinkResponce) int {
+	if link.Redirect_type == 0 {
+		return http.StatusFound
+	}
+	return link.Redirect_type
 }
 
-func GenerateShortCode(url string) string {
-	hash := md5.Sum([]byte(url))
-	encoded := base64.URLEncoding.EncodeToString(hash[:])
-	shortCode := encoded[:6]
-	return strings.TrimRight(shortCode, "=")
+// markState fills the computed expired and active flags before links are returned.
+func markState(links ...*dto.LinkResponce) {
+	now := time.Now()
+	for _, link := range links {
+		link.Expired = link.IsExpired(now)
+		link.Active = !link.Expired && link.IsActive(now)
+	}
 }
 
 func (a *App) Routes(r *gin.Engine) {
 	//r.Use(JSONValidationMiddleware())
+	// POST carries the password form, and 307/308 links forward it.
 	r.GET("/r/:code", a.Redirect)
+	r.HEAD("/r/:code", a.Redirect)
+	r.POST("/r/:code", a.Redirect)
 	r.POST("/api/links", a.CreateLinks)
 	r.GET("/api/links", a.GetLinks)
 	r.GET("/api/links/:id", a.HandleLink)
 	r.PUT("/api/links/:id", a.HandleLink)
 	r.DELETE("/api/links/:id", a.HandleLink)
+	r.GET("/api/domains", a.ListDomains)
+	r.POST("/api/domains", a.CreateDomain)
+	r.DELETE("/api/domains/:id", a.DeleteDomain)
+	r.GET("/api/reserved_names", a.ListReservedNames)
+	r.POST("/api/reserved_names", a.CreateReservedName)
+	r.DELETE("/api/reserved_names/:name", a.DeleteReservedName)
+	r.GET("/api/links/:id/stats", a.LinkStats)
+	r.GET("/api/links/:id/referrers", a.TopReferrers)
 	r.GET("/api/link_visits", a.GetVisits)
 
 	r.NoRoute(func(c *gin.Context) {
@@ -125,22 +241,191 @@ func (a *App) Routes(r *gin.Engine) {
 
 func (a *App) Redirect(c *gin.Context) {
 	code := c.Param("code")
-	link, err := a.Repo.GetLinkByShortName(a.Ctx, code)
+	// A trailing "+" asks for the preview page, whatever the link says.
+	code, forcePreview := strings.CutSuffix(code, "+")
+	resolve := a.Repo.ResolveLink
+	if a.CaseInsensitive {
+		resolve = a.Repo.ResolveLinkFold
+	}
+	link, err := resolve(a.Ctx, requestHost(c), code)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+			return
+		}
+		respondWithRepoError(c, err)
 		return
 	}
 
+	now := time.Now()
 	visit := dto.Visit{
 		LinkID:    link.Id,
 		IP:        c.ClientIP(),
 		UserAgent: c.Request.UserAgent(),
-		Status:    http.StatusFound,
-		CreatedAt: time.Now(),
+		Referer:   c.Request.Referer(),
+		Status:    redirectStatus(link),
+		CreatedAt: now,
+	}
+	visit.RefererDomain = refererDomain(visit.Referer)
+	visit.UAFamily = uaFamily(visit.UserAgent)
+	visit.Country = a.Geo.Country(visit.IP)
+	if link.IsExpired(now) {
+		a.serveExpired(c, link, visit)
+		return
+	}
+	if !link.IsActive(now) {
+		a.serveInactive(c, link, visit)
+		return
+	}
+	if link.Password_hash != nil {
+		if !a.checkPassword(c, link) {
+			return
+		}
+		// The visitor arrives here through the form POST; 303 turns it back
+		// into a GET so the password is not replayed to the destination.
+		visit.Status = http.StatusSeeOther
 	}
-	_ = a.Repo.RecordVisit(a.Ctx, visit)
+	// The preview counts as the click: its Continue button leads straight
+	// to the destination, not back through /r/.
+	preview := forcePreview || link.Preview
+	if preview {
+		visit.Status = http.StatusOK
+	}
+	target, rule := selectTarget(link, visit.UserAgent, visit.Country)
+	visit.Target = rule
+	if rule == "" && len(link.Variants) > 0 {
+		variant := pickVariant(c, link)
+		target = variant.Url
+		visit.Variant = variant.Name
+	}
+	if !a.countClick(c, link, visit) {
+		return
+	}
+	rememberVariant(c, link, visit.Variant)
+
+	destination := destinationURL(link, target, c.Request.URL.RawQuery)
+	if preview {
+		renderPage(c, http.StatusOK, previewPage, previewData{Link: link, Destination: destination})
+		return
+	}
+	c.Redirect(visit.Status, destination)
+}
+
+// clickLimitStatus answers, and is recorded for, a visit refused because
+// the link reached max_clicks. It is not 410, so that clients and the stats
+// can tell a used-up link from an expired one.
+const clickLimitStatus = http.StatusForbidden
+
+// countClick records the visit and counts it toward max_clicks. HEAD
+// requests come from link unfurlers and crawlers rather than visitors, so
+// they are only checked against the limit. It reports false once it has
+// responded.
+func (a *App) countClick(c *gin.Context, link *dto.LinkResponce, visit dto.Visit) bool {
+	var allowed bool
+	var err error
+	if c.Request.Method == http.MethodHead {
+		allowed = link.Max_clicks == nil || link.Clicks < *link.Max_clicks
+	} else {
+		allowed, err = a.Repo.RecordClick(a.Ctx, visit, clickLimitStatus)
+	}
+	if err != nil && link.Max_clicks != nil {
+		// Without the counter there is no way to tell whether the limit holds.
+		respondWithRepoError(c, err)
+		return false
+	}
+	if err == nil && !allowed {
+		c.JSON(clickLimitStatus, gin.H{"error": "Link click limit reached"})
+		return false
+	}
+	return true
+}
+
+// recordVisit logs a visit that does not count toward max_clicks. HEAD
+// requests are not logged, as in countClick.
+func (a *App) recordVisit(c *gin.Context, visit dto.Visit) {
+	if c.Request.Method != http.MethodHead {
+		_ = a.Repo.RecordVisit(a.Ctx, visit)
+	}
+}
+
+// checkPassword guards a protected link. It shows the password form and
+// returns false until the visitor posts the right password.
+func (a *App) checkPassword(c *gin.Context, link *dto.LinkResponce) bool {
+	// Only a submitted password is an attempt; any other request, a POST
+	// without one included, just gets the form.
+	password := c.PostForm("password")
+	if c.Request.Method != http.MethodPost || password == "" {
+		renderPage(c, http.StatusOK, passwordPage, passwordForm{Link: link})
+		return false
+	}
+	guard := a.passwordGuard()
+	ip := c.ClientIP()
+	now := time.Now()
+	if ok, wait := guard.allow(ip, link.Id, now); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderPage(c, http.StatusTooManyRequests, passwordPage, passwordForm{Link: link, Error: "Слишком много попыток, попробуйте позже"})
+		return false
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(*link.Password_hash), []byte(password))
+	if err != nil {
+		guard.fail(ip, link.Id, now)
+		renderPage(c, http.StatusUnauthorized, passwordPage, passwordForm{Link: link, Error: "Неверный пароль"})
+		return false
+	}
+	guard.succeed(ip, link.Id)
+	return true
+}
+
+// hashPassword turns the password from a request into what the repository
+// expects: nil keeps the stored hash, "" removes it, anything else is hashed.
+func hashPassword(password *string) (*string, error) {
+	if password == nil || *password == "" {
+		return password, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	hashed := string(hash)
+	return &hashed, nil
+}
 
-	c.Redirect(http.StatusFound, link.Original_url)
+// serveExpired answers for a link past its expires_at according to its
+// expired_action. The visit is still logged with the status that was served.
+// The fallback is always a temporary redirect, whatever the link's
+// redirect_type: it must stop applying if the link is extended.
+func (a *App) serveExpired(c *gin.Context, link *dto.LinkResponce, visit dto.Visit) {
+	switch link.Expired_action {
+	case dto.ExpiredActionFallback:
+		visit.Status = http.StatusFound
+		visit.Fallback = true
+		a.recordVisit(c, visit)
+		c.Redirect(http.StatusFound, link.Expired_url)
+	case dto.ExpiredActionPage:
+		visit.Status = http.StatusGone
+		a.recordVisit(c, visit)
+		renderPage(c, http.StatusGone, expiredPage, link)
+	default:
+		visit.Status = http.StatusGone
+		a.recordVisit(c, visit)
+		c.JSON(http.StatusGone, gin.H{"error": "Link expired"})
+	}
+}
+
+// serveInactive answers for a link before its starts_at or outside its
+// schedule. Like the expired fallback, the inactive fallback is always a
+// temporary redirect.
+func (a *App) serveInactive(c *gin.Context, link *dto.LinkResponce, visit dto.Visit) {
+	if link.Inactive_action == dto.InactiveActionFallback {
+		visit.Status = http.StatusFound
+		visit.Fallback = true
+		a.recordVisit(c, visit)
+		c.Redirect(http.StatusFound, link.Inactive_url)
+		return
+	}
+	visit.Status = http.StatusNotFound
+	a.recordVisit(c, visit)
+	renderPage(c, http.StatusNotFound, inactivePage, link)
 }
 
 func (a *App) HandleLink(rw *gin.Context) {
@@ -154,9 +439,10 @@ func (a *App) HandleLink(rw *gin.Context) {
 		}
 		link, err := a.Repo.GetLinkByID(a.Ctx, id)
 		if err != nil {
-			rw.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			respondWithRepoError(rw, err)
 			return
 		}
+		a.present(rw, link)
 		rw.JSON(http.StatusOK, link)
 		
 	case "PUT":
@@ -186,57 +472,55 @@ func (a *App) HandleLink(rw *gin.Context) {
 			respondWithBadRequest(rw, "invalid request")
 			return
 		}
-		validationErrors := make(map[string]string)
-		if request.Original_url == "" {
-			validationErrors["original_url"] = "обязательное поле"
-		} else if !isValidURL(request.Original_url) {
-			validationErrors["original_url"] = "некорректный URL"
-		}
-		if request.Short_name != "" && !isValidShortName(request.Short_name) {
-			if len(request.Short_name) < 3 || len(request.Short_name) > 32 {
-				validationErrors["short_name"] = "длина должна быть от 3 до 32 символов"
-			} else {
-				validationErrors["short_name"] = "может содержать только буквы, цифры, дефисы и подчеркивания"
-			}
-		}
-
+		validationErrors := request.Validate()
 		if len(validationErrors) > 0 {
 			respondWithValidationErrors(rw, validationErrors)
 			return
 		}
-		if request.Short_name != "" {
-			exists, err := a.Repo.CheckShortNameExists(a.Ctx, request.Short_name)
+		if !a.checkLinkHost(rw, &request) {
+			return
+		}
+		request.Short_name = a.normalizeShortName(request.Short_name)
+		if request.Short_name != "" && !a.checkReservedName(rw, request.Short_name, id) {
+			return
+		}
+		if request.Short_name != "" && (request.Host == "" || a.CaseInsensitive) {
+			exists, err := a.shortNameExists(request.Host, request.Short_name)
 			if err != nil {
-				rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				respondWithRepoError(rw, err)
 				return
 			}
 			if exists {
 				currentLink, err := a.Repo.GetLinkByID(a.Ctx, id)
-				if err != nil || currentLink.Short_name != request.Short_name {
-					respondWithValidationError(rw, "short_name", "уже существует")
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					respondWithRepoError(rw, err)
+					return
+				}
+				if err != nil || currentLink.Host != request.Host ||
+					!a.sameShortName(currentLink.Short_name, request.Short_name) {
+					respondWithShortNameTaken(rw)
 					return
 				}
 			}
 		}
-		if request.Short_name == "" {
-			request.Short_name = GenerateUniqueString()
-		}
-		responce := dto.LinkResponce{
-			Id:           id,
-			Original_url: request.Original_url,
-			Short_name:   request.Short_name,
-			Short_url:    GenerateShortCode(request.Original_url),
+		responce := newLink(request, request.Short_name)
+		responce.Id = id
+		passwordHash, err := hashPassword(request.Password)
+		if err != nil {
+			rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
 		}
-		err1 := a.Repo.UpdateLink(a.Ctx, responce)
-		if err1 != nil {
-			if strings.Contains(err1.Error(), "unique constraint") || 
-			   strings.Contains(err1.Error(), "duplicate") {
-				respondWithValidationError(rw, "short_name", "уже существует")
+		responce.Password_hash = passwordHash
+		if request.Short_name == "" {
+			if err := a.saveWithGeneratedName(&responce, a.Repo.UpdateLink); err != nil {
+				respondWithGeneratedNameError(rw, err)
 				return
 			}
-			rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		} else if err1 := a.Repo.UpdateLink(a.Ctx, responce); err1 != nil {
+			respondWithSaveError(rw, err1)
 			return
 		}
+		a.present(rw, &responce)
 		rw.JSON(http.StatusOK, responce)
 	case "DELETE":
 		id, err2 := strconv.Atoi(req)
@@ -246,7 +530,7 @@ func (a *App) HandleLink(rw *gin.Context) {
 		}
 		err := a.Repo.DeleteLinkByID(a.Ctx, id)
 		if err != nil {
-			rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			respondWithRepoError(rw, err)
 			return
 		}
 		rw.Status(204)
@@ -274,121 +558,243 @@ func (a *App) CreateLinks(rw *gin.Context) {
 		respondWithBadRequest(rw, "invalid request")
 		return
 	}
-	validationErrors := make(map[string]string)
-	if request.Original_url == "" {
-		validationErrors["original_url"] = "обязательное поле"
-	} else if !isValidURL(request.Original_url) {
-		validationErrors["original_url"] = "некорректный URL"
-	}
-	if request.Short_name != "" && !isValidShortName(request.Short_name) {
-		if len(request.Short_name) < 3 || len(request.Short_name) > 32 {
-			validationErrors["short_name"] = "длина должна быть от 3 до 32 символов"
-		} else {
-			validationErrors["short_name"] = "может содержать только буквы, цифры, дефисы и подчеркивания"
-		}
-	}
+	validationErrors := request.Validate()
 	if len(validationErrors) > 0 {
 		respondWithValidationErrors(rw, validationErrors)
 		return
 	}
-	if request.Short_name != "" {
-		exists, err := a.Repo.CheckShortNameExists(a.Ctx, request.Short_name)
+	if !a.checkLinkHost(rw, &request) {
+		return
+	}
+	request.Short_name = a.normalizeShortName(request.Short_name)
+	if request.Short_name != "" && !a.checkReservedName(rw, request.Short_name, 0) {
+		return
+	}
+	// Names on custom domains are left to the unique index, which does not
+	// fold case.
+	if request.Short_name != "" && (request.Host == "" || a.CaseInsensitive) {
+		exists, err := a.shortNameExists(request.Host, request.Short_name)
 		if err != nil {
-			rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			respondWithRepoError(rw, err)
 			return
 		}
 		if exists {
-			respondWithValidationError(rw, "short_name", "уже существует")
+			respondWithShortNameTaken(rw)
 			return
 		}
 	}
-	shortName := request.Short_name
-	if shortName == "" {
-		shortName = GenerateUniqueString()
-	}
-	responce := dto.LinkResponce{
-		Original_url: request.Original_url,
-		Short_name:   shortName,
-		Short_url:    GenerateShortCode(request.Original_url),
+	responce := newLink(request, request.Short_name)
+	passwordHash, err := hashPassword(request.Password)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
 	}
-	err1 := a.Repo.CreateLink(a.Ctx, responce)
-	if err1 != nil {
-		if strings.Contains(err1.Error(), "unique constraint") || 
-		   strings.Contains(err1.Error(), "duplicate") {
-			respondWithValidationError(rw, "short_name", "уже существует")
+	responce.Password_hash = passwordHash
+	if request.Short_name == "" {
+		if err := a.saveWithGeneratedName(&responce, a.Repo.CreateLink); err != nil {
+			respondWithGeneratedNameError(rw, err)
 			return
 		}
-		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	} else if err1 := a.Repo.CreateLink(a.Ctx, responce); err1 != nil {
+		respondWithSaveError(rw, err1)
 		return
 	}
 
+	a.present(rw, &responce)
 	rw.JSON(http.StatusCreated, responce)
 }
 
 func (a *App) GetLinks(rw *gin.Context) {
-	allLinks, _ := a.Repo.ListLinks(a.Ctx)
-	total := len(allLinks)
-	rangeParam := rw.Query("range")
-	if rangeParam == "" {
-		rw.Header("Content-Range", fmt.Sprintf("links 0-%d/%d", total-1, total))
-		rw.JSON(http.StatusOK, allLinks)
+	if rw.Query("filter") != "" || rw.Query("sort") != "" {
+		a.queryLinks(rw)
 		return
 	}
-	rangeParam = strings.Trim(rangeParam, "[]")
-	parts := strings.Split(rangeParam, ",")
-	if len(parts) != 2 {
-		respondWithBadRequest(rw, "range must be in format [start,end]")
+	start, end, ranged, problem := parseRange(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
 		return
 	}
-	start, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
-	end, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err1 != nil || err2 != nil {
-		respondWithBadRequest(rw, "range values must be integers")
+	if !ranged {
+		allLinks, err := a.Repo.ListLinks(a.Ctx)
+		if err != nil {
+			respondWithRepoError(rw, err)
+			return
+		}
+		total := len(allLinks)
+		rw.Header("Content-Range", contentRange("links", 0, total, total))
+		a.present(rw, allLinks...)
+		rw.JSON(http.StatusOK, allLinks)
 		return
 	}
-	if start < 0 || end < 0 || start > end {
-		respondWithBadRequest(rw, "invalid range values")
+	// Only the requested page is loaded; the total is counted by the database.
+	total, err := a.Repo.CountLinks(a.Ctx, dto.LinkFilter{})
+	if err != nil {
+		respondWithRepoError(rw, err)
 		return
 	}
-	responce, err := a.Repo.ListLinksLimited(a.Ctx, start, end)
+	responce, err := a.Repo.ListLinksLimited(a.Ctx, start, end-start+1)
 	if err != nil {
-		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		respondWithRepoError(rw, err)
 		return
 	}
-	rw.Header("Content-Range", fmt.Sprintf("links %d-%d/%d", start, end, total))
+	rw.Header("Content-Range", contentRange("links", start, len(responce), total))
+	a.present(rw, responce...)
 	rw.JSON(http.StatusOK, responce)
 }
 
 func (a *App) GetVisits(rw *gin.Context) {
-	allVisits, _ := a.Repo.ListVisits(a.Ctx)
-	total := len(allVisits)
-	rangeParam := rw.Query("range")
-	if rangeParam == "" {
-		rw.Header("Content-Range", fmt.Sprintf("visits 0-%d/%d", total-1, total))
-		rw.JSON(http.StatusOK, allVisits)
+	if rw.Query("after_id") != "" || rw.Query("limit") != "" {
+		a.visitsAfter(rw)
 		return
 	}
-	rangeParam = strings.Trim(rangeParam, "[]")
-	parts := strings.Split(rangeParam, ",")
-	if len(parts) != 2 {
-		respondWithBadRequest(rw, "range must be in format [start,end]")
+	if rw.Query("filter") != "" || rw.Query("sort") != "" {
+		a.queryVisits(rw)
 		return
 	}
-	start, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
-	end, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err1 != nil || err2 != nil {
-		respondWithBadRequest(rw, "range values must be integers")
+	start, end, ranged, problem := parseRange(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
 		return
 	}
-	if start < 0 || end < 0 || start > end {
-		respondWithBadRequest(rw, "invalid range values")
+	if !ranged {
+		allVisits, err := a.Repo.ListVisits(a.Ctx)
+		if err != nil {
+			respondWithRepoError(rw, err)
+			return
+		}
+		total := len(allVisits)
+		rw.Header("Content-Range", contentRange("visits", 0, total, total))
+		rw.JSON(http.StatusOK, allVisits)
 		return
 	}
-	responce, err := a.Repo.ListVisitsLimited(a.Ctx, start, end)
+	total, err := a.Repo.CountVisits(a.Ctx, dto.VisitFilter{})
 	if err != nil {
-		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		respondWithRepoError(rw, err)
 		return
 	}
-	rw.Header("Content-Range", fmt.Sprintf("visits %d-%d/%d", start, end, total))
+	responce, err := a.Repo.ListVisitsLimited(a.Ctx, start, end-start+1)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	rw.Header("Content-Range", contentRange("visits", start, len(responce), total))
 	rw.JSON(http.StatusOK, responce)
-}
\ No newline at end of file
+}
+
+// queryLinks serves GET /api/links with filter and/or sort. The
+// Content-Range total is the number of links matching the filter.
+func (a *App) queryLinks(rw *gin.Context) {
+	filter, problem := parseLinkFilter(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	column, desc, problem := parseSort(rw, dto.LinkSortColumns)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	start, end, ranged, problem := parseRange(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	query := dto.LinkQuery{LinkFilter: filter, Sort: column, Desc: desc}
+	if ranged {
+		query.Start = start
+		query.Limit = end - start + 1
+	}
+	total, err := a.Repo.CountLinks(a.Ctx, filter)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	links, err := a.Repo.QueryLinks(a.Ctx, query)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	if links == nil {
+		links = []*dto.LinkResponce{}
+	}
+	rw.Header("Content-Range", contentRange("links", start, len(links), total))
+	a.present(rw, links...)
+	rw.JSON(http.StatusOK, links)
+}
+
+// queryVisits serves GET /api/link_visits with filter and/or sort. The
+// Content-Range total is the number of visits matching the filter.
+func (a *App) queryVisits(rw *gin.Context) {
+	filter, problem := parseVisitFilter(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	column, desc, problem := parseSort(rw, dto.VisitSortColumns)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	start, end, ranged, problem := parseRange(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	query := dto.VisitQuery{VisitFilter: filter, Sort: column, Desc: desc}
+	if ranged {
+		query.Start = start
+		query.Limit = end - start + 1
+	}
+	total, err := a.Repo.CountVisits(a.Ctx, filter)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	visits, err := a.Repo.QueryVisits(a.Ctx, query)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	if visits == nil {
+		visits = []*dto.Visit{}
+	}
+	rw.Header("Content-Range", contentRange("visits", start, len(visits), total))
+	rw.JSON(http.StatusOK, visits)
+}
+
+// visitsAfter serves GET /api/link_visits in cursor mode: after_id and
+// limit instead of range, optionally with filter. The body is a
+// dto.VisitPage and no Content-Range is sent, since counting would cost
+// what the cursor saves.
+func (a *App) visitsAfter(rw *gin.Context) {
+	if rw.Query("range") != "" || rw.Query("sort") != "" {
+		respondWithBadRequest(rw, "after_id and limit cannot be combined with range or sort")
+		return
+	}
+	afterID, limit, problem := parseCursor(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	filter, problem := parseVisitFilter(rw)
+	if problem != "" {
+		respondWithBadRequest(rw, problem)
+		return
+	}
+	// One extra row tells whether there is a next page.
+	visits, err := a.Repo.VisitsAfter(a.Ctx, filter, afterID, limit+1)
+	if err != nil {
+		respondWithRepoError(rw, err)
+		return
+	}
+	page := dto.VisitPage{Data: visits}
+	if len(visits) > limit {
+		page.Data = visits[:limit]
+		next := page.Data[limit-1].Id
+		page.Next_cursor = &next
+	}
+	if page.Data == nil {
+		page.Data = []*dto.Visit{}
+	}
+	rw.JSON(http.StatusOK, page)
+}
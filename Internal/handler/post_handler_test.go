@@ -5,14 +5,25 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go-project-278/Internal/dto"
+	"go-project-278/Internal/geoip"
 	"go-project-278/Internal/handler"
+	"go-project-278/Internal/repository"
+	"go-project-278/Internal/shortcode"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type MockRepository struct {
@@ -71,11 +82,164 @@ func (m *MockRepository) GetLinkByShortName(ctx context.Context, shortName strin
 	return args.Get(0).(*dto.LinkResponce), args.Error(1)
 }
 
+func (m *MockRepository) ResolveLink(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
+	args := m.Called(ctx, host, shortName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.LinkResponce), args.Error(1)
+}
+
+func (m *MockRepository) ResolveLinkFold(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
+	args := m.Called(ctx, host, shortName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.LinkResponce), args.Error(1)
+}
+
+func (m *MockRepository) CheckShortNameExistsFold(ctx context.Context, host, shortName string) (bool, error) {
+	args := m.Called(ctx, host, shortName)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ListDomains(ctx context.Context) ([]dto.Domain, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dto.Domain), args.Error(1)
+}
+
+func (m *MockRepository) GetDomainByHost(ctx context.Context, host string) (*dto.Domain, error) {
+	args := m.Called(ctx, host)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.Domain), args.Error(1)
+}
+
+func (m *MockRepository) CreateDomain(ctx context.Context, host string) (*dto.Domain, error) {
+	args := m.Called(ctx, host)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.Domain), args.Error(1)
+}
+
+func (m *MockRepository) DeleteDomain(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListReservedNames(ctx context.Context) ([]dto.ReservedName, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dto.ReservedName), args.Error(1)
+}
+
+func (m *MockRepository) IsReservedName(ctx context.Context, name string) (bool, error) {
+	args := m.Called(ctx, name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) CreateReservedName(ctx context.Context, name string) (*dto.ReservedName, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.ReservedName), args.Error(1)
+}
+
+func (m *MockRepository) DeleteReservedName(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
 func (m *MockRepository) RecordVisit(ctx context.Context, visit dto.Visit) error {
 	args := m.Called(ctx, visit)
 	return args.Error(0)
 }
 
+func (m *MockRepository) RecordClick(ctx context.Context, visit dto.Visit, refusedStatus int) (bool, error) {
+	args := m.Called(ctx, visit, refusedStatus)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) VariantClicks(ctx context.Context, linkID int) (map[string]int, error) {
+	args := m.Called(ctx, linkID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockRepository) TopReferrers(ctx context.Context, linkID, limit int) ([]dto.RefererCount, error) {
+	args := m.Called(ctx, linkID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dto.RefererCount), args.Error(1)
+}
+
+func (m *MockRepository) VisitStats(ctx context.Context, q dto.StatsQuery) (*dto.VisitStats, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.VisitStats), args.Error(1)
+}
+
+func (m *MockRepository) QueryLinks(ctx context.Context, q dto.LinkQuery) ([]*dto.LinkResponce, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*dto.LinkResponce), args.Error(1)
+}
+
+func (m *MockRepository) CountLinks(ctx context.Context, f dto.LinkFilter) (int, error) {
+	args := m.Called(ctx, f)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) NextShortCodeID(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// fixedCodes hands out the given short names in order.
+type fixedCodes []string
+
+func (f *fixedCodes) Generate(ctx context.Context) (string, error) {
+	code := (*f)[0]
+	*f = (*f)[1:]
+	return code, nil
+}
+
+func (m *MockRepository) VisitsAfter(ctx context.Context, f dto.VisitFilter, afterID, limit int) ([]*dto.Visit, error) {
+	args := m.Called(ctx, f, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*dto.Visit), args.Error(1)
+}
+
+func (m *MockRepository) QueryVisits(ctx context.Context, q dto.VisitQuery) ([]*dto.Visit, error) {
+	args := m.Called(ctx, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*dto.Visit), args.Error(1)
+}
+
+func (m *MockRepository) CountVisits(ctx context.Context, f dto.VisitFilter) (int, error) {
+	args := m.Called(ctx, f)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockRepository) ListVisits(ctx context.Context) ([]*dto.Visit, error) {
 	args := m.Called(ctx)
 	if args.Get(0) == nil { return nil, args.Error(1) }
@@ -89,6 +253,7 @@ func (m *MockRepository) ListVisitsLimited(ctx context.Context, start, limit int
 }
 func TestCreateLinks_Success(t *testing.T) {
 	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "test-short").Return(false, nil)
 	mockRepo.On("CheckShortNameExists", mock.Anything, "test-short").
 		Return(false, nil)
 	mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).
@@ -119,6 +284,7 @@ func TestCreateLinks_Success(t *testing.T) {
 
 func TestCreateLinks_Success_AutoGenerateShortName(t *testing.T) {
 	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, mock.Anything).Return(false, nil)
 	mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).
 		Return(nil)
 
@@ -140,9 +306,114 @@ func TestCreateLinks_Success_AutoGenerateShortName(t *testing.T) {
 	app.CreateLinks(c)
 	
 	assert.Equal(t, http.StatusCreated, w.Code)
+	var link dto.LinkResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Regexp(t, `^[0-9A-Za-z]{7}$`, link.Short_name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLinks_WordsShortName(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, mock.Anything).Return(false, nil)
+	mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).Return(nil)
+	app := &handler.App{
+		Ctx:   context.Background(),
+		Repo:  mockRepo,
+		Codes: shortcode.NewWords(),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(`{"original_url": "https://example.com"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var link dto.LinkResponce
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Regexp(t, `^[a-z]+-[a-z]+-[0-9]{2}$`, link.Short_name)
+}
+
+func TestCreateLinks_InvalidGeneratedShortName(t *testing.T) {
+	mockRepo := &MockRepository{}
+	codes := fixedCodes{"no spaces allowed"}
+	app := &handler.App{
+		Ctx:   context.Background(),
+		Repo:  mockRepo,
+		Codes: &codes,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(`{"original_url": "https://example.com"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateLink", mock.Anything, mock.Anything)
+}
+
+func TestCreateLinks_GeneratedShortNameSkipsReserved(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "held").Return(true, nil)
+	mockRepo.On("IsReservedName", mock.Anything, "free1").Return(false, nil)
+	mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+		return link.Short_name == "free1"
+	})).Return(nil)
+	// "admin" is reserved by the built-in list, "held" through the API.
+	codes := fixedCodes{"admin", "held", "free1"}
+	router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, Codes: &codes})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com"}`)))
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var link dto.LinkResponce
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "free1", link.Short_name)
+	mockRepo.AssertNumberOfCalls(t, "CreateLink", 1)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateLinks_GeneratedShortNameRetriesOnConflict(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, mock.Anything).Return(false, nil)
+	taken := mock.MatchedBy(func(link dto.LinkResponce) bool { return link.Short_name != "free1" })
+	mockRepo.On("CreateLink", mock.Anything, taken).
+		Return(fmt.Errorf("create link: %w", repository.ErrConflict))
+	mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+		return link.Short_name == "free1"
+	})).Return(nil)
+	codes := fixedCodes{"taken1", "taken2", "free1"}
+	app := &handler.App{
+		Ctx:   context.Background(),
+		Repo:  mockRepo,
+		Codes: &codes,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(`{"original_url": "https://example.com"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var link dto.LinkResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "free1", link.Short_name)
+	mockRepo.AssertNumberOfCalls(t, "CreateLink", 3)
+	mockRepo.AssertNotCalled(t, "CheckShortNameExists", mock.Anything, mock.Anything)
+
+	// Every attempt taken: the client may simply try again.
+	codes = fixedCodes{"taken1", "taken2", "taken3", "taken4", "taken5"}
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(`{"original_url": "https://example.com"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 func TestCreateLinks_BadRequest_InvalidJSON(t *testing.T) {
 	mockRepo := &MockRepository{}
 	app := &handler.App{
@@ -165,6 +436,7 @@ func TestCreateLinks_BadRequest_InvalidJSON(t *testing.T) {
 
 func TestCreateLinks_InternalServerError(t *testing.T) {
 	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "test-short").Return(false, nil)
 	mockRepo.On("CheckShortNameExists", mock.Anything, "test-short").
 		Return(false, nil)
 	mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).
@@ -286,6 +558,7 @@ func TestCreateLinks_ValidationError_ShortNameTooLong(t *testing.T) {
 
 func TestCreateLinks_ValidationError_ShortNameDuplicate(t *testing.T) {
 	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "existing").Return(false, nil)
 	mockRepo.On("CheckShortNameExists", mock.Anything, "existing").
 		Return(true, nil)
 
@@ -307,7 +580,38 @@ func TestCreateLinks_ValidationError_ShortNameDuplicate(t *testing.T) {
 	
 	app.CreateLinks(c)
 	
-	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLinks_ValidationError_ShortNameConflictOnInsert(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "raced").Return(false, nil)
+	mockRepo.On("CheckShortNameExists", mock.Anything, "raced").
+		Return(false, nil)
+	mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).
+		Return(fmt.Errorf("create link: %w", repository.ErrConflict))
+
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	jsonData := `{
+		"original_url": "https://example.com",
+		"short_name": "raced"
+	}`
+
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "short_name")
 	mockRepo.AssertExpectations(t)
 }
 
@@ -343,7 +647,7 @@ func TestHandleLink_GET_NotFound(t *testing.T) {
 	mockRepo := &MockRepository{}
 	
 	mockRepo.On("GetLinkByID", mock.Anything, 999).
-		Return(nil, errors.New("link not found"))
+		Return(nil, repository.ErrNotFound)
 
 	app := &handler.App{
 		Ctx:  context.Background(),
@@ -362,8 +666,31 @@ func TestHandleLink_GET_NotFound(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestHandleLink_GET_StorageUnavailable(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetLinkByID", mock.Anything, 1).
+		Return(nil, fmt.Errorf("get link: %w", repository.ErrUnavailable))
+
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	c.Request = httptest.NewRequest("GET", "/api/links/1", nil)
+
+	app.HandleLink(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestHandleLink_PUT_Success(t *testing.T) {
     mockRepo := &MockRepository{}
+    mockRepo.On("IsReservedName", mock.Anything, "updated-name").Return(false, nil)
     mockRepo.On("CheckShortNameExists", mock.Anything, "updated-name").
         Return(false, nil)  
     mockRepo.On("UpdateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).
@@ -394,6 +721,28 @@ func TestHandleLink_PUT_Success(t *testing.T) {
     mockRepo.AssertExpectations(t)
 }
 
+func TestHandleLink_PUT_ShortNameTaken(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "taken").Return(false, nil)
+	mockRepo.On("CheckShortNameExists", mock.Anything, "taken").Return(true, nil)
+	mockRepo.On("GetLinkByID", mock.Anything, 1).Return(&dto.LinkResponce{Id: 1, Short_name: "mine"}, nil)
+
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	c.Request = httptest.NewRequest("PUT", "/api/links/1", strings.NewReader(`{"original_url":"https://example.com","short_name":"taken"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.HandleLink(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"errors":{"short_name":"уже существует"}}`, w.Body.String())
+	mockRepo.AssertNotCalled(t, "UpdateLink", mock.Anything, mock.Anything)
+}
+
 func TestHandleLink_DELETE_Success(t *testing.T) {
 	mockRepo := &MockRepository{}
 	
@@ -460,6 +809,28 @@ func TestHandleLink_DELETE_NotFound(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestHandleLink_DELETE_Missing(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("DeleteLinkByID", mock.Anything, 7).
+		Return(fmt.Errorf("delete link: %w", repository.ErrNotFound))
+
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "7"}}
+	c.Request = httptest.NewRequest("DELETE", "/api/links/7", nil)
+
+	app.HandleLink(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetLinks_Success(t *testing.T) {
 	mockRepo := &MockRepository{}
 	
@@ -549,9 +920,9 @@ func TestGetLinksLimited_Success(t *testing.T) {
             Short_url:    "def456",
         },
     }
-    mockRepo.On("ListLinks", mock.Anything).
-        Return(expectedLinks, nil)
-    mockRepo.On("ListLinksLimited", mock.Anything, 0, 10).
+    mockRepo.On("CountLinks", mock.Anything, dto.LinkFilter{}).
+        Return(2, nil)
+    mockRepo.On("ListLinksLimited", mock.Anything, 0, 11).
         Return(expectedLinks, nil)  
     app := &handler.App{
         Ctx:  context.Background(),
@@ -570,17 +941,18 @@ func TestGetLinksLimited_Success(t *testing.T) {
     assert.Equal(t, "test1", links[0].Short_name)
     assert.Equal(t, "https://example2.com", links[1].Original_url)
     assert.Equal(t, "test2", links[1].Short_name)
-    mockRepo.AssertCalled(t, "ListLinks", mock.Anything)
-    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 0, 10)
+    mockRepo.AssertCalled(t, "CountLinks", mock.Anything, dto.LinkFilter{})
+    mockRepo.AssertNotCalled(t, "ListLinks", mock.Anything)
+    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 0, 11)
     mockRepo.AssertExpectations(t)
 }
 
 func TestGetLinksLimited_EmptyList(t *testing.T) {
     mockRepo := &MockRepository{}
-    mockRepo.On("ListLinks", mock.Anything).
-        Return([]*dto.LinkResponce{}, nil)
+    mockRepo.On("CountLinks", mock.Anything, dto.LinkFilter{}).
+        Return(0, nil)
     
-    mockRepo.On("ListLinksLimited", mock.Anything, 5, 15).
+    mockRepo.On("ListLinksLimited", mock.Anything, 5, 11).
         Return([]*dto.LinkResponce{}, nil)
     app := &handler.App{
         Ctx:  context.Background(),
@@ -595,18 +967,17 @@ func TestGetLinksLimited_EmptyList(t *testing.T) {
     err := json.Unmarshal(w.Body.Bytes(), &links)
     assert.NoError(t, err)
     assert.Empty(t, links)
-    mockRepo.AssertCalled(t, "ListLinks", mock.Anything)
-    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 5, 15)
+    mockRepo.AssertCalled(t, "CountLinks", mock.Anything, dto.LinkFilter{})
+    mockRepo.AssertNotCalled(t, "ListLinks", mock.Anything)
+    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 5, 11)
     mockRepo.AssertExpectations(t)
 }
 
 func TestGetLinksLimited_DatabaseError(t *testing.T) {
     mockRepo := &MockRepository{}
-    mockRepo.On("ListLinks", mock.Anything).
-        Return([]*dto.LinkResponce{
-            {Id: 1, Original_url: "https://example.com", Short_name: "test"},
-        }, nil)
-    mockRepo.On("ListLinksLimited", mock.Anything, 0, 10).
+    mockRepo.On("CountLinks", mock.Anything, dto.LinkFilter{}).
+        Return(1, nil)
+    mockRepo.On("ListLinksLimited", mock.Anything, 0, 11).
         Return(nil, errors.New("database error"))
     app := &handler.App{
         Ctx:  context.Background(),
@@ -621,8 +992,9 @@ func TestGetLinksLimited_DatabaseError(t *testing.T) {
     err := json.Unmarshal(w.Body.Bytes(), &response)
     assert.NoError(t, err)
     assert.Contains(t, response, "error")
-    mockRepo.AssertCalled(t, "ListLinks", mock.Anything)
-    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 0, 10)
+    mockRepo.AssertCalled(t, "CountLinks", mock.Anything, dto.LinkFilter{})
+    mockRepo.AssertNotCalled(t, "ListLinks", mock.Anything)
+    mockRepo.AssertCalled(t, "ListLinksLimited", mock.Anything, 0, 11)
     mockRepo.AssertExpectations(t)
 }
 
@@ -653,8 +1025,8 @@ func TestRedirect_Success(t *testing.T) {
 		Original_url: "https://example.com",
 		Short_name:   "testcode",
 	}
-	mockRepo.On("GetLinkByShortName", mock.Anything, "testcode").Return(expectedLink, nil)
-	mockRepo.On("RecordVisit", mock.Anything, mock.AnythingOfType("dto.Visit")).Return(nil)
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "testcode").Return(expectedLink, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(true, nil)
 	app := &handler.App{
 		Ctx:  context.Background(),
 		Repo: mockRepo,
@@ -668,39 +1040,1631 @@ func TestRedirect_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetVisits_Success_WithRange(t *testing.T) {
+func TestRedirect_Expired(t *testing.T) {
+	expiredAt := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		action     string
+		wantStatus int
+		check      func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{"gone", dto.ExpiredActionGone, http.StatusGone, func(t *testing.T, w *httptest.ResponseRecorder) {
+			assert.Contains(t, w.Body.String(), "Link expired")
+		}},
+		{"fallback", dto.ExpiredActionFallback, http.StatusFound, func(t *testing.T, w *httptest.ResponseRecorder) {
+			assert.Equal(t, "https://example.com/over", w.Header().Get("Location"))
+		}},
+		{"page", dto.ExpiredActionPage, http.StatusGone, func(t *testing.T, w *httptest.ResponseRecorder) {
+			assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+			assert.Contains(t, w.Body.String(), "promo")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "promo").Return(&dto.LinkResponce{
+				Id:             1,
+				Original_url:   "https://example.com",
+				Short_name:     "promo",
+				Expires_at:     &expiredAt,
+				Expired_action: tt.action,
+				Expired_url:    "https://example.com/over",
+			}, nil)
+			mockRepo.On("RecordVisit", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Status == tt.wantStatus && v.Fallback == (tt.action == dto.ExpiredActionFallback)
+			})).Return(nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/r/promo", nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			tt.check(t, w)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedirect_Inactive(t *testing.T) {
+	startsAt := time.Now().Add(time.Hour)
+	// A window that starts two hours from now is closed right now.
+	opens := time.Now().UTC().Add(2 * time.Hour)
+	closed := &dto.Schedule{
+		Days:     []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"},
+		From:     opens.Format("15:04"),
+		To:       opens.Add(time.Hour).Format("15:04"),
+		Timezone: "UTC",
+	}
+	tests := []struct {
+		name       string
+		link       dto.LinkResponce
+		wantStatus int
+		location   string
+	}{
+		{"before starts_at", dto.LinkResponce{Starts_at: &startsAt, Inactive_action: dto.InactiveActionNotFound}, http.StatusNotFound, ""},
+		{"outside schedule", dto.LinkResponce{Schedule: closed}, http.StatusNotFound, ""},
+		{"fallback", dto.LinkResponce{Schedule: closed, Inactive_action: dto.InactiveActionFallback,
+			Inactive_url: "https://example.com/closed"}, http.StatusFound, "https://example.com/closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := tt.link
+			link.Id = 1
+			link.Original_url = "https://example.com"
+			link.Short_name = "sale"
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "sale").Return(&link, nil)
+			mockRepo.On("RecordVisit", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Status == tt.wantStatus && v.Fallback == (tt.location != "")
+			})).Return(nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/r/sale", nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+			if tt.wantStatus == http.StatusNotFound {
+				assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+			}
+			mockRepo.AssertExpectations(t)
+			mockRepo.AssertNotCalled(t, "RecordClick")
+		})
+	}
+}
+
+func TestHandleLink_PUT_ValidationError_Schedule(t *testing.T) {
 	mockRepo := &MockRepository{}
-	allVisits := []*dto.Visit{{Id: 1}, {Id: 2}, {Id: 3}}
-	mockRepo.On("ListVisits", mock.Anything).Return(allVisits, nil)
-	limitedVisits := []*dto.Visit{{Id: 1}, {Id: 2}}
-	mockRepo.On("ListVisitsLimited", mock.Anything, 0, 1).Return(limitedVisits, nil)
 	app := &handler.App{
 		Ctx:  context.Background(),
 		Repo: mockRepo,
 	}
-	router := setupTestRouter(app)
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/link_visits?range=[0,1]", nil)
-	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "visits 0-1/3", w.Header().Get("Content-Range"))
-	mockRepo.AssertExpectations(t)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+
+	jsonData := `{
+		"original_url": "https://example.com",
+		"starts_at": "2031-01-01T00:00:00Z",
+		"expires_at": "2030-01-01T00:00:00Z",
+		"schedule": {"days": ["mon", "someday"], "from": "9:00", "to": "25:00", "timezone": "Mars/Olympus"},
+		"inactive_action": "fallback"
+	}`
+	c.Request = httptest.NewRequest("PUT", "/api/links/1", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.HandleLink(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response handler.ValidationErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	for _, field := range []string{"starts_at", "schedule.days", "schedule.from", "schedule.to", "schedule.timezone", "inactive_url"} {
+		assert.Contains(t, response.Errors, field)
+	}
+	mockRepo.AssertNotCalled(t, "UpdateLink")
 }
 
-func TestNoRoute_ReturnsJSON(t *testing.T) {
+func TestCreateLinks_ValidationError_FallbackWithoutURL(t *testing.T) {
 	mockRepo := &MockRepository{}
-	app := &handler.App{Repo: mockRepo}
-	router := setupTestRouter(app)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
 
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/api/undefined-route", nil)
-	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusNotFound, w.Code)
-	assert.Contains(t, w.Body.String(), "Запрашиваемый ресурс не найден")
-}
-
+	c, _ := gin.CreateTestContext(w)
 
+	jsonData := `{
+		"original_url": "https://example.com",
+		"expires_at": "2030-01-01T00:00:00Z",
+		"expired_action": "fallback"
+	}`
+
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "expired_url")
+}
+
+func TestRedirect_UsesLinkRedirectType(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "docs").Return(&dto.LinkResponce{
+		Id:            1,
+		Original_url:  "https://example.com/docs",
+		Short_name:    "docs",
+		Redirect_type: http.StatusPermanentRedirect,
+	}, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+		return v.Status == http.StatusPermanentRedirect
+	}), http.StatusForbidden).Return(true, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	for _, method := range []string{"GET", "POST"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/r/docs", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, method)
+		assert.Equal(t, "https://example.com/docs", w.Header().Get("Location"), method)
+	}
+	for _, method := range []string{"PUT", "DELETE", "PATCH"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/r/docs", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRedirect_QueryPassthroughAndUTM(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     dto.LinkResponce
+		path     string
+		location string
+	}{
+		{
+			name:     "query dropped by default",
+			link:     dto.LinkResponce{Original_url: "https://example.com/p?a=1#top"},
+			path:     "/r/promo?utm_source=tg",
+			location: "https://example.com/p?a=1#top",
+		},
+		{
+			name:     "passthrough appends after destination params",
+			link:     dto.LinkResponce{Original_url: "https://example.com/p?a=1&b=x%20y#top", Query_passthrough: true},
+			path:     "/r/promo?utm_source=tg&c=3",
+			location: "https://example.com/p?a=1&b=x%20y&utm_source=tg&c=3#top",
+		},
+		{
+			name: "destination wins",
+			link: dto.LinkResponce{Original_url: "https://example.com/p?a=1&b=2", Query_passthrough: true,
+				Query_precedence: dto.QueryPrecedenceDestination},
+			path:     "/r/promo?a=9&c=3",
+			location: "https://example.com/p?a=1&b=2&c=3",
+		},
+		{
+			name: "request wins",
+			link: dto.LinkResponce{Original_url: "https://example.com/p?a=1&b=2&a=5", Query_passthrough: true,
+				Query_precedence: dto.QueryPrecedenceRequest},
+			path:     "/r/promo?a=9&a=8&c=3",
+			location: "https://example.com/p?a=9&a=8&b=2&c=3",
+		},
+		{
+			name: "utm template",
+			link: dto.LinkResponce{Original_url: "https://example.com/p?utm_source=old#x", Utm_source: "mail",
+				Utm_medium: "email", Utm_campaign: "spring {short_name}"},
+			path:     "/r/promo?utm_source=tg",
+			location: "https://example.com/p?utm_source=mail&utm_medium=email&utm_campaign=spring+promo#x",
+		},
+		{
+			name: "request overrides utm template",
+			link: dto.LinkResponce{Original_url: "https://example.com/p", Utm_source: "mail", Utm_medium: "email",
+				Query_passthrough: true, Query_precedence: dto.QueryPrecedenceRequest},
+			path:     "/r/promo?utm_source=tg",
+			location: "https://example.com/p?utm_source=tg&utm_medium=email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := tt.link
+			link.Id = 1
+			link.Short_name = "promo"
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "promo").Return(&link, nil)
+			mockRepo.On("RecordClick", mock.Anything, mock.Anything, http.StatusForbidden).Return(true, nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirect_DeviceTargeting(t *testing.T) {
+	link := &dto.LinkResponce{
+		Id:           1,
+		Original_url: "https://example.com/app",
+		Short_name:   "app",
+		Targets: []dto.TargetRule{
+			{Match: dto.TargetIOS, Url: "https://apps.apple.com/app/id1"},
+			{Match: dto.TargetAndroid, Url: "https://play.google.com/store/apps/details?id=com.example"},
+		},
+	}
+	tests := []struct {
+		name      string
+		userAgent string
+		location  string
+		target    string
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", "https://apps.apple.com/app/id1", dto.TargetIOS},
+		{"android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/124.0 Mobile Safari/537.36", "https://play.google.com/store/apps/details?id=com.example", dto.TargetAndroid},
+		{"desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36", "https://example.com/app", ""},
+		{"no user agent", "", "https://example.com/app", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "app").Return(link, nil)
+			mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Target == tt.target
+			}), http.StatusForbidden).Return(true, nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/r/app", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateLinks_ValidationError_Targets(t *testing.T) {
+	mockRepo := &MockRepository{}
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
 
+	jsonData := `{
+		"original_url": "https://example.com",
+		"targets": [{"match": "tv", "url": "not a url"}]
+	}`
+
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response handler.ValidationErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Contains(t, response.Errors, "targets[0].match")
+	assert.Contains(t, response.Errors, "targets[0].url")
+	mockRepo.AssertNotCalled(t, "CreateLink")
+}
+
+func TestCreateLinks_TagsAndDomain(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "tagged").Return(false, nil)
+	mockRepo.On("CheckShortNameExists", mock.Anything, "tagged").Return(false, nil)
+	mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+		return assert.ObjectsAreEqual([]string{"promo", "лето"}, link.Tags) &&
+			link.Original_domain == "example.com"
+	})).Return(nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	jsonData := `{
+		"original_url": "https://WWW.Example.com:8443/sale",
+		"short_name": "tagged",
+		"tags": ["Promo", "Лето"]
+	}`
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
 
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	jsonData = `{
+		"original_url": "https://example.com",
+		"tags": ["promo", "PROMO", "no spaces"]
+	}`
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+	app.CreateLinks(c)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response handler.ValidationErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "уже существует", response.Errors["tags[1]"])
+	assert.Contains(t, response.Errors, "tags[2]")
+}
 
+func TestRedirect_GeoTargeting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	assert.NoError(t, os.WriteFile(path, []byte("5.0.0.0,5.255.255.255,DE\n1.0.0.0,1.0.0.255,AU\n"), 0o644))
+	geo, err := geoip.Open(path)
+	assert.NoError(t, err)
+
+	link := &dto.LinkResponce{
+		Id:           1,
+		Original_url: "https://example.com/",
+		Short_name:   "shop",
+		Targets:      []dto.TargetRule{{Match: dto.TargetIOS, Url: "https://apps.apple.com/app/id1"}},
+		Geo_targets:  map[string]string{"DE": "https://example.com/de/"},
+	}
+	tests := []struct {
+		name      string
+		ip        string
+		userAgent string
+		location  string
+		visit     dto.Visit
+	}{
+		{"country match", "5.9.10.11", "curl/8.0", "https://example.com/de/", dto.Visit{Country: "DE", Target: "geo:DE"}},
+		{"country without target", "1.0.0.7", "curl/8.0", "https://example.com/", dto.Visit{Country: "AU"}},
+		{"unknown address", "192.0.2.1", "curl/8.0", "https://example.com/", dto.Visit{}},
+		{"device rule first", "5.9.10.11", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)", "https://apps.apple.com/app/id1", dto.Visit{Country: "DE", Target: dto.TargetIOS}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "shop").Return(link, nil)
+			mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Country == tt.visit.Country && v.Target == tt.visit.Target
+			}), http.StatusForbidden).Return(true, nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+				Geo:  geo,
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/r/shop", nil)
+			req.RemoteAddr = tt.ip + ":40000"
+			req.Header.Set("User-Agent", tt.userAgent)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedirect_Preview(t *testing.T) {
+	createdAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		preview bool
+		path    string
+	}{
+		{"link flag", true, "/r/docs"},
+		{"trailing plus", false, "/r/docs+"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "docs").Return(&dto.LinkResponce{
+				Id:           1,
+				Original_url: "https://example.com/docs?page=1",
+				Short_name:   "docs",
+				Title:        "Project <docs>",
+				Created_at:   &createdAt,
+				Preview:      tt.preview,
+				Utm_source:   "preview",
+			}, nil)
+			mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Status == http.StatusOK
+			}), http.StatusForbidden).Return(true, nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("Location"))
+			body := w.Body.String()
+			assert.Contains(t, body, "Project &lt;docs&gt;")
+			assert.Contains(t, body, `href="https://example.com/docs?page=1&amp;utm_source=preview"`)
+			assert.Contains(t, body, "01.04.2025")
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedirect_RecordsReferer(t *testing.T) {
+	tests := []struct {
+		referer string
+		domain  string
+	}{
+		{"https://www.Google.com:443/search?q=x", "google.com"},
+		{"http://t.me./channel", "t.me"},
+		{"android-app://org.telegram.messenger/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.referer, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "docs").Return(&dto.LinkResponce{
+				Id:           1,
+				Original_url: "https://example.com/docs",
+				Short_name:   "docs",
+			}, nil)
+			mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+				return v.Referer == tt.referer && v.RefererDomain == tt.domain
+			}), http.StatusForbidden).Return(true, nil)
+			app := &handler.App{
+				Ctx:  context.Background(),
+				Repo: mockRepo,
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/r/docs", nil)
+			req.Header.Set("Referer", tt.referer)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusFound, w.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTopReferrers(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetLinkByID", mock.Anything, 1).Return(&dto.LinkResponce{Id: 1}, nil)
+	mockRepo.On("GetLinkByID", mock.Anything, 2).Return(nil, repository.ErrNotFound)
+	mockRepo.On("TopReferrers", mock.Anything, 1, 2).Return([]dto.RefererCount{
+		{Domain: "t.me", Visits: 5},
+		{Domain: "google.com", Visits: 3},
+	}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/links/1/referrers?limit=2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"domain":"t.me","visits":5},{"domain":"google.com","visits":3}]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/links/1/referrers?limit=0", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/links/2/referrers", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRedirect_Variants(t *testing.T) {
+	link := &dto.LinkResponce{
+		Id:              7,
+		Original_url:    "https://example.com/landing",
+		Short_name:      "split",
+		Sticky_variants: true,
+		Variants: []dto.Variant{
+			{Name: "a", Url: "https://example.com/a", Weight: 1},
+			{Name: "b", Url: "https://example.com/b", Weight: 0},
+		},
+	}
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "split").Return(link, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+		return v.Variant == "a"
+	}), http.StatusForbidden).Return(true, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/r/split", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/a", w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "ab_7=a")
+
+	// A remembered variant that no longer gets traffic is not reused.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/r/split", nil)
+	req.AddCookie(&http.Cookie{Name: "ab_7", Value: "b"})
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "https://example.com/a", w.Header().Get("Location"))
+	mockRepo.AssertNumberOfCalls(t, "RecordClick", 2)
+}
+
+func TestRedirect_StickyVariantNotSetWhenRefused(t *testing.T) {
+	maxClicks := 1
+	link := &dto.LinkResponce{
+		Id:              7,
+		Original_url:    "https://example.com/landing",
+		Short_name:      "split",
+		Sticky_variants: true,
+		Max_clicks:      &maxClicks,
+		Variants:        []dto.Variant{{Name: "a", Url: "https://example.com/a", Weight: 1}},
+	}
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "split").Return(link, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(false, nil)
+	router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/r/split", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestRedirect_StickyVariantFromCookie(t *testing.T) {
+	link := &dto.LinkResponce{
+		Id:              7,
+		Original_url:    "https://example.com/landing",
+		Short_name:      "split",
+		Sticky_variants: true,
+		Variants: []dto.Variant{
+			{Name: "a", Url: "https://example.com/a", Weight: 1},
+			{Name: "b", Url: "https://example.com/b", Weight: 1},
+		},
+	}
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "split").Return(link, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+		return v.Variant == "b"
+	}), http.StatusForbidden).Return(true, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/r/split", nil)
+		req.AddCookie(&http.Cookie{Name: "ab_7", Value: "b"})
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "https://example.com/b", w.Header().Get("Location"))
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLinkStats(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetLinkByID", mock.Anything, 7).Return(&dto.LinkResponce{
+		Id:     7,
+		Clicks: 6,
+		Variants: []dto.Variant{
+			{Name: "a", Url: "https://example.com/a", Weight: 1},
+			{Name: "b", Url: "https://example.com/b", Weight: 1},
+		},
+	}, nil)
+	mockRepo.On("VariantClicks", mock.Anything, 7).Return(map[string]int{"a": 4, "old": 2}, nil)
+	mockRepo.On("VisitStats", mock.Anything, mock.Anything).Return(&dto.VisitStats{}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/links/7/stats", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats dto.LinkStats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 7, stats.Link_id)
+	assert.Equal(t, 6, stats.Clicks)
+	assert.Equal(t, []dto.VariantStats{
+		{Variant: dto.Variant{Name: "a", Url: "https://example.com/a", Weight: 1}, Clicks: 4},
+		{Variant: dto.Variant{Name: "b", Url: "https://example.com/b", Weight: 1}, Clicks: 0},
+		{Variant: dto.Variant{Name: "old"}, Clicks: 2},
+	}, stats.Variants)
+}
+
+func TestLinkStats_Range(t *testing.T) {
+	from := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(72 * time.Hour)
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetLinkByID", mock.Anything, 7).Return(&dto.LinkResponce{Id: 7, Clicks: 40}, nil)
+	mockRepo.On("VariantClicks", mock.Anything, 7).Return(map[string]int{}, nil)
+	mockRepo.On("VisitStats", mock.Anything, dto.StatsQuery{
+		LinkID: 7, From: from, To: to, Interval: dto.StatsIntervalDay, Top: 3,
+	}).Return(&dto.VisitStats{
+		Total_clicks:    5,
+		Unique_visitors: 4,
+		Timeline:        []dto.TimelinePoint{{Time: from.Add(24 * time.Hour), Clicks: 5}},
+		Top_referrers:   []dto.RefererCount{{Domain: "t.me", Visits: 3}},
+		Top_user_agents: []dto.UserAgentCount{{Family: "Chrome", Visits: 4}, {Family: "", Visits: 2}},
+		Statuses:        map[int]int{302: 5, 410: 1},
+	}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/links/7/stats?from=2025-05-01T03:00:00%2B03:00&to=2025-05-04T00:00:00Z&top=3", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"link_id": 7,
+		"clicks": 40,
+		"from": "2025-05-01T00:00:00Z",
+		"to": "2025-05-04T00:00:00Z",
+		"interval": "day",
+		"total_clicks": 5,
+		"unique_visitors": 4,
+		"timeline": [
+			{"time": "2025-05-01T00:00:00Z", "clicks": 0},
+			{"time": "2025-05-02T00:00:00Z", "clicks": 5},
+			{"time": "2025-05-03T00:00:00Z", "clicks": 0}
+		],
+		"top_referrers": [{"domain": "t.me", "visits": 3}],
+		"top_user_agents": [{"family": "Chrome", "visits": 4}, {"family": "unknown", "visits": 2}],
+		"statuses": {"302": 5, "410": 1},
+		"variants": []
+	}`, w.Body.String())
+
+	for _, query := range []string{
+		"interval=week",
+		"from=yesterday",
+		"from=2025-05-04T00:00:00Z&to=2025-05-01T00:00:00Z",
+		"interval=hour&from=2025-01-01T00:00:00Z&to=2025-03-01T00:00:00Z",
+		"top=0",
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/links/7/stats?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestCreateLinks_ValidationError_RedirectType(t *testing.T) {
+	mockRepo := &MockRepository{}
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	jsonData := `{
+		"original_url": "https://example.com",
+		"redirect_type": 303
+	}`
+
+	c.Request = httptest.NewRequest("POST", "/api/links", bytes.NewBufferString(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	app.CreateLinks(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "redirect_type")
+}
+
+func TestRedirect_PasswordProtected(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	passwordHash := string(hash)
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "private").Return(&dto.LinkResponce{
+		Id:            1,
+		Original_url:  "https://example.com/internal",
+		Short_name:    "private",
+		Redirect_type: http.StatusPermanentRedirect,
+		Password_hash: &passwordHash,
+	}, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.MatchedBy(func(v dto.Visit) bool {
+		return v.Status == http.StatusSeeOther
+	}), http.StatusForbidden).Return(true, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	post := func(password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/r/private", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/r/private", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	assert.NotContains(t, w.Body.String(), "example.com/internal")
+
+	w = post("wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	w = post("s3cret")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://example.com/internal", w.Header().Get("Location"))
+
+	// Posts without a password are not attempts and do not use up any.
+	for i := 0; i < 10; i++ {
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/r/private", strings.NewReader("other=field"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="password"`)
+	}
+	for i := 0; i < 4; i++ {
+		post("guess")
+	}
+	w = post("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = post("guess")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = post("s3cret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	mockRepo.AssertNumberOfCalls(t, "RecordClick", 1)
+}
+
+func TestRedirect_PasswordGuessesFromOthersDoNotLockLink(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	passwordHash := string(hash)
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "private").Return(&dto.LinkResponce{
+		Id:            1,
+		Original_url:  "https://example.com/internal",
+		Short_name:    "private",
+		Password_hash: &passwordHash,
+	}, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(true, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	post := func(ip, password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/r/private", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Wrong guesses from many addresses lock out only those addresses.
+	for i := 0; i < 100; i++ {
+		ip := fmt.Sprintf("198.51.100.%d", i)
+		for j := 0; j < 5; j++ {
+			post(ip, "guess")
+		}
+		w := post(ip, "s3cret")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "900", w.Header().Get("Retry-After"))
+	}
+	w := post("203.0.113.1", "s3cret")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://example.com/internal", w.Header().Get("Location"))
+}
+
+func TestHandleLink_GET_HidesPasswordHash(t *testing.T) {
+	mockRepo := &MockRepository{}
+	passwordHash := "$2a$10$examplehash"
+	mockRepo.On("GetLinkByID", mock.Anything, 1).Return(&dto.LinkResponce{
+		Id:            1,
+		Original_url:  "https://example.com",
+		Short_name:    "private",
+		Password_hash: &passwordHash,
+	}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+	c.Request = httptest.NewRequest("GET", "/api/links/1", nil)
+	app.HandleLink(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "examplehash")
+	assert.NotContains(t, w.Body.String(), "password")
+}
+
+func TestRedirect_ClickLimitReached(t *testing.T) {
+	mockRepo := &MockRepository{}
+	maxClicks := 1
+	mockRepo.On("ResolveLink", mock.Anything, mock.Anything, "once").Return(&dto.LinkResponce{
+		Id:           1,
+		Original_url: "https://example.com/download",
+		Short_name:   "once",
+		Max_clicks:   &maxClicks,
+		Clicks:       1,
+	}, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(false, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/r/once", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRedirect_HeadDoesNotUseClicks(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	maxClicks := 1
+	require.NoError(t, repo.CreateLink(context.Background(), dto.LinkResponce{
+		Original_url:   "https://example.com/download",
+		Short_name:     "once",
+		Expired_action: dto.ExpiredActionGone,
+		Redirect_type:  http.StatusFound,
+		Max_clicks:     &maxClicks,
+	}))
+	router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: repo})
+
+	for _, tt := range []struct {
+		method string
+		want   int
+	}{
+		{"HEAD", http.StatusFound},
+		{"HEAD", http.StatusFound},
+		{"GET", http.StatusFound},
+		{"HEAD", http.StatusForbidden},
+		{"GET", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, "/r/once", nil))
+		assert.Equal(t, tt.want, w.Code, tt.method)
+	}
+	visits, err := repo.ListVisits(context.Background())
+	require.NoError(t, err)
+	require.Len(t, visits, 2, "HEAD requests are not recorded")
+	statuses := []int{visits[0].Status, visits[1].Status}
+	assert.ElementsMatch(t, []int{http.StatusFound, http.StatusForbidden}, statuses)
+}
+
+func TestGetVisits_Success_WithRange(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("CountVisits", mock.Anything, dto.VisitFilter{}).Return(3, nil)
+	limitedVisits := []*dto.Visit{{Id: 1}, {Id: 2}}
+	mockRepo.On("ListVisitsLimited", mock.Anything, 0, 2).Return(limitedVisits, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/link_visits?range=[0,1]", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "visits 0-1/3", w.Header().Get("Content-Range"))
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ListVisits", mock.Anything)
+}
+
+func TestGetLinks_InclusiveRange(t *testing.T) {
+	page := make([]*dto.LinkResponce, 10)
+	for i := range page {
+		page[i] = &dto.LinkResponce{Id: 11 + i}
+	}
+	mockRepo := &MockRepository{}
+	mockRepo.On("CountLinks", mock.Anything, dto.LinkFilter{}).Return(25, nil)
+	mockRepo.On("ListLinksLimited", mock.Anything, 10, 10).Return(page, nil)
+	mockRepo.On("ListLinksLimited", mock.Anything, 30, 10).Return([]*dto.LinkResponce{}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/links?range=[10,19]", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "links 10-19/25", w.Header().Get("Content-Range"))
+	var links []dto.LinkResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &links))
+	assert.Len(t, links, 10)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/links?range=[30,39]", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "links */25", w.Header().Get("Content-Range"))
+}
+
+func TestGetLinks_SearchFilterAndSort(t *testing.T) {
+	filter := dto.LinkFilter{Q: "promo", Domain: "example.com", Tag: "summer"}
+	mockRepo := &MockRepository{}
+	mockRepo.On("CountLinks", mock.Anything, filter).Return(3, nil)
+	mockRepo.On("QueryLinks", mock.Anything, dto.LinkQuery{
+		LinkFilter: filter, Sort: "created_at", Desc: true, Start: 0, Limit: 10,
+	}).Return([]*dto.LinkResponce{{Id: 4, Short_name: "promo-4"}}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	params := url.Values{
+		"filter": {`{"q":" promo ","domain":"WWW.Example.com","tag":"Summer"}`},
+		"sort":   {`["created_at","DESC"]`},
+		"range":  {"[0,9]"},
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/links?"+params.Encode(), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "links 0-0/3", w.Header().Get("Content-Range"))
+	mockRepo.AssertExpectations(t)
+
+	for _, bad := range []url.Values{
+		{"filter": {`{"q":["promo"]}`}},
+		{"sort": {`["clicks; DROP TABLE links","ASC"]`}},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/links?"+bad.Encode(), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, bad.Encode())
+	}
+}
+
+// TestGetLinks_GetMany sends what react-admin's getMany sends for a
+// reference field, along with a reference target the links do not have.
+func TestGetLinks_GetMany(t *testing.T) {
+	filter := dto.LinkFilter{IDs: []int{4, 7}}
+	mockRepo := &MockRepository{}
+	mockRepo.On("CountLinks", mock.Anything, filter).Return(2, nil)
+	mockRepo.On("QueryLinks", mock.Anything, dto.LinkQuery{LinkFilter: filter}).
+		Return([]*dto.LinkResponce{{Id: 4, Short_name: "promo"}, {Id: 7, Short_name: "docs"}}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	for _, query := range []string{
+		"filter=" + url.QueryEscape(`{"id":[4,7]}`),
+		"filter=" + url.QueryEscape(`{"id":[4,7],"author_id":12}`),
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/links?"+query, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "links 0-1/2", w.Header().Get("Content-Range"))
+		var links []dto.LinkResponce
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &links))
+		assert.Len(t, links, 2)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetVisits_Cursor(t *testing.T) {
+	linkID := 3
+	mockRepo := &MockRepository{}
+	mockRepo.On("VisitsAfter", mock.Anything, dto.VisitFilter{LinkID: &linkID}, 0, 3).
+		Return([]*dto.Visit{{Id: 90}, {Id: 80}, {Id: 70}}, nil)
+	mockRepo.On("VisitsAfter", mock.Anything, dto.VisitFilter{LinkID: &linkID}, 80, 3).
+		Return([]*dto.Visit{{Id: 70}}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	get := func(query string) dto.VisitPage {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/link_visits?"+query, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Empty(t, w.Header().Get("Content-Range"))
+		var page dto.VisitPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+	filter := url.QueryEscape(`{"link_id":3}`)
+	page := get("limit=2&filter=" + filter)
+	require.Len(t, page.Data, 2)
+	require.NotNil(t, page.Next_cursor)
+	assert.Equal(t, 80, *page.Next_cursor)
+
+	page = get("limit=2&after_id=80&filter=" + filter)
+	assert.Len(t, page.Data, 1)
+	assert.Nil(t, page.Next_cursor)
+	mockRepo.AssertExpectations(t)
+
+	for _, bad := range []string{"limit=0", "limit=5000", "after_id=-1", "after_id=x", "limit=10&range=[0,9]", "limit=10&sort=" + url.QueryEscape(`["ip","ASC"]`)} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/link_visits?"+bad, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, bad)
+	}
+}
+
+func TestGetVisits_FilterAndSort(t *testing.T) {
+	linkID := 3
+	status := 302
+	from := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	filter := dto.VisitFilter{LinkID: &linkID, Status: &status, CreatedFrom: &from}
+	mockRepo := &MockRepository{}
+	mockRepo.On("CountVisits", mock.Anything, filter).Return(12, nil)
+	mockRepo.On("QueryVisits", mock.Anything, dto.VisitQuery{
+		VisitFilter: filter, Sort: "ip", Desc: false, Start: 10, Limit: 5,
+	}).Return([]*dto.Visit{{Id: 7, LinkID: 3}, {Id: 9, LinkID: 3}}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	params := url.Values{
+		"filter": {`{"link_id":3,"status":302,"created_at_gte":"2025-05-01T00:00:00Z"}`},
+		"sort":   {`["ip","ASC"]`},
+		"range":  {"[10,14]"},
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/link_visits?"+params.Encode(), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "visits 10-11/12", w.Header().Get("Content-Range"))
+	var visits []dto.Visit
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &visits))
+	assert.Len(t, visits, 2)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestGetVisits_AdminReferences sends what react-admin sends for a link's
+// visits (getManyReference) and for visits picked by id (getMany).
+func TestGetVisits_AdminReferences(t *testing.T) {
+	linkID := 3
+	mockRepo := &MockRepository{}
+	byLink := dto.VisitFilter{LinkID: &linkID}
+	mockRepo.On("CountVisits", mock.Anything, byLink).Return(30, nil)
+	mockRepo.On("QueryVisits", mock.Anything, dto.VisitQuery{
+		VisitFilter: byLink, Sort: "created_at", Desc: true, Start: 0, Limit: 25,
+	}).Return([]*dto.Visit{{Id: 9, LinkID: 3}, {Id: 8, LinkID: 3}}, nil)
+	byIDs := dto.VisitFilter{IDs: []int{8, 9}}
+	mockRepo.On("CountVisits", mock.Anything, byIDs).Return(2, nil)
+	mockRepo.On("QueryVisits", mock.Anything, dto.VisitQuery{VisitFilter: byIDs}).
+		Return([]*dto.Visit{{Id: 9, LinkID: 3}, {Id: 8, LinkID: 3}}, nil)
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+
+	for query, wantRange := range map[string]string{
+		url.Values{
+			"filter": {`{"link_id":3}`},
+			"sort":   {`["created_at","DESC"]`},
+			"range":  {"[0,24]"},
+		}.Encode(): "visits 0-1/30",
+		// A target the visits do not have is ignored.
+		url.Values{
+			"filter": {`{"link_id":3,"campaign_id":5}`},
+			"sort":   {`["created_at","DESC"]`},
+			"range":  {"[0,24]"},
+		}.Encode(): "visits 0-1/30",
+		"filter=" + url.QueryEscape(`{"id":[8,9]}`): "visits 0-1/2",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/link_visits?"+query, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, wantRange, w.Header().Get("Content-Range"), query)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetVisits_InvalidFilterOrSort(t *testing.T) {
+	mockRepo := &MockRepository{}
+	app := &handler.App{
+		Ctx:  context.Background(),
+		Repo: mockRepo,
+	}
+	router := setupTestRouter(app)
+	for _, params := range []url.Values{
+		{"filter": {`{"link_id":"three"}`}},
+		{"filter": {`{"created_at_gte":"yesterday"}`}},
+		{"sort": {`["password","ASC"]`}},
+		{"sort": {`["ip","UP"]`}},
+		{"sort": {`ip`}},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/link_visits?"+params.Encode(), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, params.Encode())
+	}
+	mockRepo.AssertNotCalled(t, "QueryVisits")
+}
+
+func TestNoRoute_ReturnsJSON(t *testing.T) {
+	mockRepo := &MockRepository{}
+	app := &handler.App{Repo: mockRepo}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/undefined-route", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Запрашиваемый ресурс не найден")
+}
+
+
+
+
+
+
+func TestCreateLinks_ShortURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		host    string
+		proto   string
+		want    string
+	}{
+		{"base url", "https://sho.rt", "api.internal:8080", "", "https://sho.rt/r/test-short"},
+		{"base url with prefix", "https://example.org/links", "api.internal", "", "https://example.org/links/r/test-short"},
+		{"request host", "", "api.example.com:8080", "", "http://api.example.com:8080/r/test-short"},
+		{"forwarded https", "", "api.example.com", "https", "https://api.example.com/r/test-short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			mockRepo.On("IsReservedName", mock.Anything, "test-short").Return(false, nil)
+			mockRepo.On("CheckShortNameExists", mock.Anything, "test-short").Return(false, nil)
+			mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+				return link.Short_url == "" && link.Host == ""
+			})).Return(nil)
+			app := &handler.App{Ctx: context.Background(), Repo: mockRepo, BaseURL: tt.baseURL}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"test-short"}`))
+			req.Host = tt.host
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			var link dto.LinkResponce
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+			assert.Equal(t, tt.want, link.Short_url)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateLinks_CustomDomain(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetDomainByHost", mock.Anything, "go.team.io").Return(&dto.Domain{Id: 1, Host: "go.team.io"}, nil)
+	mockRepo.On("IsReservedName", mock.Anything, "abc").Return(false, nil)
+	mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+		return link.Host == "go.team.io" && link.Short_name == "abc"
+	})).Return(nil)
+	app := &handler.App{Ctx: context.Background(), Repo: mockRepo, BaseURL: "https://sho.rt"}
+	router := setupTestRouter(app)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"abc","host":" Go.Team.io "}`))
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var link dto.LinkResponce
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, "go.team.io", link.Host)
+	assert.Equal(t, "https://go.team.io/r/abc", link.Short_url)
+	// The short name only has to be free on its own domain.
+	mockRepo.AssertNotCalled(t, "CheckShortNameExists", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLinks_CustomDomainErrors(t *testing.T) {
+	t.Run("unregistered host", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("GetDomainByHost", mock.Anything, "lnk.other.io").Return(nil, repository.ErrNotFound)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","host":"lnk.other.io"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"errors":{"host":"домен не зарегистрирован"}}`, w.Body.String())
+		mockRepo.AssertNotCalled(t, "CreateLink", mock.Anything, mock.Anything)
+	})
+	t.Run("short name taken on the domain", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("GetDomainByHost", mock.Anything, "go.team.io").Return(&dto.Domain{Id: 1, Host: "go.team.io"}, nil)
+		mockRepo.On("IsReservedName", mock.Anything, "abc").Return(false, nil)
+		mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).Return(repository.ErrConflict)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"abc","host":"go.team.io"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"errors":{"short_name":"уже существует"}}`, w.Body.String())
+	})
+}
+
+func TestRedirect_ResolvesByHost(t *testing.T) {
+	mockRepo := &MockRepository{}
+	mockRepo.On("ResolveLink", mock.Anything, "go.team.io", "abc").
+		Return(&dto.LinkResponce{Id: 1, Original_url: "https://example.com/team", Short_name: "abc", Host: "go.team.io"}, nil)
+	mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(true, nil)
+	router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/r/abc", nil)
+	req.Host = "GO.Team.io:443"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/team", w.Header().Get("Location"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDomains(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	t.Run("list", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("ListDomains", mock.Anything).Return([]dto.Domain{
+			{Id: 2, Host: "go.team.io", Created_at: created},
+			{Id: 1, Host: "lnk.other.io", Created_at: created},
+		}, nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/domains", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "domains 0-1/2", w.Header().Get("Content-Range"))
+		var domains []dto.Domain
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &domains))
+		require.Len(t, domains, 2)
+		assert.Equal(t, "go.team.io", domains[0].Host)
+	})
+	t.Run("create", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("CreateDomain", mock.Anything, "go.team.io").Return(&dto.Domain{Id: 3, Host: "go.team.io", Created_at: created}, nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/domains", strings.NewReader(`{"host":"Go.Team.IO"}`)))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"id":3,"host":"go.team.io","created_at":"2026-01-02T03:04:05Z"}`, w.Body.String())
+	})
+	t.Run("create rejects bad hosts", func(t *testing.T) {
+		for _, host := range []string{"", "https://go.team.io", "go.team.io:8080", "localhost", "go_team.io"} {
+			mockRepo := &MockRepository{}
+			router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+			body, _ := json.Marshal(dto.DomainRequest{Host: host})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/domains", bytes.NewReader(body)))
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, host)
+			mockRepo.AssertNotCalled(t, "CreateDomain", mock.Anything, mock.Anything)
+		}
+	})
+	t.Run("create duplicate", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("CreateDomain", mock.Anything, "go.team.io").Return(nil, repository.ErrConflict)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/domains", strings.NewReader(`{"host":"go.team.io"}`)))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"errors":{"host":"уже существует"}}`, w.Body.String())
+	})
+	t.Run("delete", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("DeleteDomain", mock.Anything, 1).Return(nil)
+		mockRepo.On("DeleteDomain", mock.Anything, 2).Return(repository.ErrConflict)
+		mockRepo.On("DeleteDomain", mock.Anything, 3).Return(repository.ErrNotFound)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+
+		for id, want := range map[string]int{
+			"1":   http.StatusNoContent,
+			"2":   http.StatusConflict,
+			"3":   http.StatusNotFound,
+			"abc": http.StatusBadRequest,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/domains/"+id, nil))
+			assert.Equal(t, want, w.Code, id)
+		}
+	})
+}
+
+func TestCreateLinks_ReservedShortName(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortName string
+		mockRepo  func(m *MockRepository)
+	}{
+		{"built-in, any case", "API", func(m *MockRepository) {}},
+		{"from the file", "promo", func(m *MockRepository) {}},
+		{"through the api", "billing", func(m *MockRepository) {
+			m.On("IsReservedName", mock.Anything, "billing").Return(true, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{}
+			tt.mockRepo(mockRepo)
+			app := &handler.App{
+				Ctx:           context.Background(),
+				Repo:          mockRepo,
+				ReservedNames: map[string]bool{"promo": true},
+			}
+			router := setupTestRouter(app)
+
+			w := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"original_url":"https://example.com","short_name":%q}`, tt.shortName)
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(body)))
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.JSONEq(t, `{"errors":{"short_name":"зарезервированное имя"}}`, w.Body.String())
+			mockRepo.AssertNotCalled(t, "CreateLink", mock.Anything, mock.Anything)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleLink_PUT_ReservedShortName(t *testing.T) {
+	put := func(router *gin.Engine, shortName string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"original_url":"https://example.com","short_name":%q}`, shortName)
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/links/5", strings.NewReader(body)))
+		return w
+	}
+
+	mockRepo := &MockRepository{}
+	mockRepo.On("GetLinkByID", mock.Anything, 5).Return(&dto.LinkResponce{Id: 5, Short_name: "other"}, nil)
+	router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+	w := put(router, "admin")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"errors":{"short_name":"зарезервированное имя"}}`, w.Body.String())
+	mockRepo.AssertNotCalled(t, "UpdateLink", mock.Anything, mock.Anything)
+
+	// A link that had the name before it was reserved keeps it.
+	mockRepo = &MockRepository{}
+	mockRepo.On("IsReservedName", mock.Anything, "billing").Return(true, nil)
+	mockRepo.On("GetLinkByID", mock.Anything, 5).Return(&dto.LinkResponce{Id: 5, Short_name: "billing"}, nil)
+	mockRepo.On("CheckShortNameExists", mock.Anything, "billing").Return(true, nil)
+	mockRepo.On("UpdateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).Return(nil)
+	router = setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo})
+	w = put(router, "billing")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReservedNames(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	newApp := func(mockRepo *MockRepository) *gin.Engine {
+		return setupTestRouter(&handler.App{
+			Ctx:           context.Background(),
+			Repo:          mockRepo,
+			ReservedNames: map[string]bool{"promo": true, "api": true},
+		})
+	}
+	t.Run("list", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("ListReservedNames", mock.Anything).
+			Return([]dto.ReservedName{{Name: "billing", Source: dto.ReservedAPI, Created_at: &created}}, nil)
+
+		w := httptest.NewRecorder()
+		newApp(mockRepo).ServeHTTP(w, httptest.NewRequest("GET", "/api/reserved_names", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var names []dto.ReservedName
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &names))
+		sources := make(map[string]string)
+		for i, name := range names {
+			if i > 0 {
+				assert.Less(t, names[i-1].Name, name.Name)
+			}
+			sources[name.Name] = name.Source
+		}
+		assert.Equal(t, dto.ReservedAPI, sources["billing"])
+		assert.Equal(t, dto.ReservedFile, sources["promo"])
+		assert.Equal(t, dto.ReservedBuiltin, sources["api"])
+		assert.Equal(t, dto.ReservedBuiltin, sources["ping"])
+		assert.Equal(t, fmt.Sprintf("reserved_names 0-%d/%d", len(names)-1, len(names)), w.Header().Get("Content-Range"))
+	})
+	t.Run("create", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("CreateReservedName", mock.Anything, "billing").
+			Return(&dto.ReservedName{Name: "billing", Source: dto.ReservedAPI, Created_at: &created}, nil)
+		mockRepo.On("CreateReservedName", mock.Anything, "taken").Return(nil, repository.ErrConflict)
+		router := newApp(mockRepo)
+
+		for body, want := range map[string]int{
+			`{"name":" Billing "}`: http.StatusCreated,
+			`{"name":"taken"}`:     http.StatusUnprocessableEntity,
+			`{"name":"Admin"}`:     http.StatusUnprocessableEntity,
+			`{"name":"promo"}`:     http.StatusUnprocessableEntity,
+			`{"name":"no"}`:        http.StatusUnprocessableEntity,
+			`{"name":""}`:          http.StatusUnprocessableEntity,
+			`{"name":"a.b.c"}`:     http.StatusUnprocessableEntity,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/reserved_names", strings.NewReader(body)))
+			assert.Equal(t, want, w.Code, body)
+		}
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("delete", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("DeleteReservedName", mock.Anything, "billing").Return(nil)
+		mockRepo.On("DeleteReservedName", mock.Anything, "missing").Return(repository.ErrNotFound)
+		router := newApp(mockRepo)
+
+		for name, want := range map[string]int{
+			"Billing": http.StatusNoContent,
+			"missing": http.StatusNotFound,
+			"ping":    http.StatusConflict,
+			"promo":   http.StatusConflict,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/reserved_names/"+name, nil))
+			assert.Equal(t, want, w.Code, name)
+		}
+	})
+}
+
+func TestCaseInsensitiveShortNames(t *testing.T) {
+	t.Run("redirect ignores case", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("ResolveLinkFold", mock.Anything, mock.Anything, "PROMO").
+			Return(&dto.LinkResponce{Id: 1, Original_url: "https://example.com", Short_name: "promo"}, nil)
+		mockRepo.On("RecordClick", mock.Anything, mock.AnythingOfType("dto.Visit"), http.StatusForbidden).Return(true, nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: true})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/r/PROMO", nil))
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		mockRepo.AssertNotCalled(t, "ResolveLink", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("create stores lower case", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("IsReservedName", mock.Anything, "promo").Return(false, nil)
+		mockRepo.On("CheckShortNameExistsFold", mock.Anything, "", "promo").Return(false, nil)
+		mockRepo.On("CreateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+			return link.Short_name == "promo"
+		})).Return(nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: true})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"Promo"}`)))
+
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var link dto.LinkResponce
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+		assert.Equal(t, "promo", link.Short_name)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("create rejects a name taken in another case", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("IsReservedName", mock.Anything, "promo").Return(false, nil)
+		mockRepo.On("CheckShortNameExistsFold", mock.Anything, "", "promo").Return(true, nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: true})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"PROMO"}`)))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"errors":{"short_name":"уже существует"}}`, w.Body.String())
+		mockRepo.AssertNotCalled(t, "CreateLink", mock.Anything, mock.Anything)
+	})
+	t.Run("create checks custom domains too", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("GetDomainByHost", mock.Anything, "go.team.io").Return(&dto.Domain{Id: 1, Host: "go.team.io"}, nil)
+		mockRepo.On("IsReservedName", mock.Anything, "promo").Return(false, nil)
+		mockRepo.On("CheckShortNameExistsFold", mock.Anything, "go.team.io", "promo").Return(true, nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: true})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com","short_name":"Promo","host":"go.team.io"}`)))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.JSONEq(t, `{"errors":{"short_name":"уже существует"}}`, w.Body.String())
+		mockRepo.AssertNotCalled(t, "CreateLink", mock.Anything, mock.Anything)
+	})
+	t.Run("update keeps the link's own name", func(t *testing.T) {
+		mockRepo := &MockRepository{}
+		mockRepo.On("IsReservedName", mock.Anything, "promo").Return(false, nil)
+		mockRepo.On("CheckShortNameExistsFold", mock.Anything, "", "promo").Return(true, nil)
+		mockRepo.On("GetLinkByID", mock.Anything, 5).Return(&dto.LinkResponce{Id: 5, Short_name: "Promo"}, nil)
+		mockRepo.On("UpdateLink", mock.Anything, mock.MatchedBy(func(link dto.LinkResponce) bool {
+			return link.Id == 5 && link.Short_name == "promo"
+		})).Return(nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: true})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/links/5", strings.NewReader(`{"original_url":"https://example.com","short_name":"PROMO"}`)))
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestCreateLinks_GeneratedShortNameAlphabet(t *testing.T) {
+	for _, tt := range []struct {
+		caseInsensitive bool
+		pattern         string
+	}{
+		{false, `^[2-9A-HJ-NP-Za-km-z]{7}$`},
+		{true, `^[2-9a-km-z]{7}$`},
+	} {
+		mockRepo := &MockRepository{}
+		mockRepo.On("IsReservedName", mock.Anything, mock.Anything).Return(false, nil)
+		mockRepo.On("CreateLink", mock.Anything, mock.AnythingOfType("dto.LinkResponce")).Return(nil)
+		router := setupTestRouter(&handler.App{Ctx: context.Background(), Repo: mockRepo, CaseInsensitive: tt.caseInsensitive})
+
+		for range 50 {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/api/links", strings.NewReader(`{"original_url":"https://example.com"}`)))
+			require.Equal(t, http.StatusCreated, w.Code)
+			var link dto.LinkResponce
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+			require.Regexp(t, tt.pattern, link.Short_name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refererDomain normalises a Referer header to the referring host: lower
+// case, without port, trailing dot or a leading "www.". Anything that is not
+// an absolute http(s) URL yields "".
+func refererDomain(referer string) string {
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return normalizeHost(u.Host)
+}
+
+// linkDomain is the host of a link's original_url, normalised the same way
+// so that links can be filtered by domain.
+func linkDomain(originalURL string) string {
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeHost(u.Host)
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	host = strings.TrimPrefix(host, "www.")
+	if len(host) > 255 {
+		return ""
+	}
+	return host
+}
+
+const (
+	defaultReferrersLimit = 10
+	maxReferrersLimit     = 100
+)
+
+// TopReferrers lists the domains that sent the most clicks to a link.
+// The optional limit query parameter caps the list (default 10, max 100).
+func (a *App) TopReferrers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondWithBadRequest(c, "invalid id")
+		return
+	}
+	limit := defaultReferrersLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxReferrersLimit {
+			respondWithBadRequest(c, "limit must be between 1 and 100")
+			return
+		}
+	}
+	if _, err := a.Repo.GetLinkByID(a.Ctx, id); err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	referrers, err := a.Repo.TopReferrers(a.Ctx, id, limit)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, referrers)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"go-project-278/Internal/dto"
+	"go-project-278/Internal/repository"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// builtinReservedNames are short names no link may take: paths of this
+// service and of the admin frontend, names likely to become routes, and
+// profanities. Matching ignores case.
+var builtinReservedNames = map[string]bool{
+	"about": true, "account": true, "admin": true, "administrator": true,
+	"api": true, "app": true, "assets": true, "auth": true, "dashboard": true,
+	"docs": true, "domains": true, "edit": true, "favicon": true, "health": true,
+	"healthz": true, "help": true, "link_visits": true, "links": true,
+	"login": true, "logout": true, "metrics": true, "new": true, "oauth": true,
+	"ping": true, "preview": true, "privacy": true, "public": true,
+	"register": true, "reserved_names": true, "robots": true, "settings": true,
+	"signin": true, "signup": true, "sitemap": true, "static": true,
+	"stats": true, "status": true, "support": true, "terms": true, "www": true,
+
+	"asshole": true, "bitch": true, "bullshit": true, "cunt": true, "dick": true,
+	"fuck": true, "fucker": true, "fuckoff": true, "motherfucker": true,
+	"nazi": true, "piss": true, "porn": true, "pussy": true, "shit": true,
+	"slut": true, "whore": true,
+}
+
+// reservedSource tells where a reserved name comes from, "" if it is not
+// reserved by the built-in or the file list.
+func (a *App) reservedSource(name string) string {
+	switch {
+	case builtinReservedNames[name]:
+		return dto.ReservedBuiltin
+	case a.ReservedNames[name]:
+		return dto.ReservedFile
+	}
+	return ""
+}
+
+func (a *App) isReservedName(name string) (bool, error) {
+	name = strings.ToLower(name)
+	if a.reservedSource(name) != "" {
+		return true, nil
+	}
+	return a.Repo.IsReservedName(a.Ctx, name)
+}
+
+// checkReservedName refuses a reserved short_name. A link that already has
+// the name keeps it, so that reserving a name does not lock its link.
+// It reports false once it has responded.
+func (a *App) checkReservedName(c *gin.Context, shortName string, linkID int) bool {
+	reserved, err := a.isReservedName(shortName)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return false
+	}
+	if !reserved {
+		return true
+	}
+	if linkID != 0 {
+		current, err := a.Repo.GetLinkByID(a.Ctx, linkID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			respondWithRepoError(c, err)
+			return false
+		}
+		if err == nil && a.sameShortName(current.Short_name, shortName) {
+			return true
+		}
+	}
+	respondWithValidationError(c, "short_name", "зарезервированное имя")
+	return false
+}
+
+// ListReservedNames lists every reserved short name by name, with where
+// it comes from.
+func (a *App) ListReservedNames(c *gin.Context) {
+	stored, err := a.Repo.ListReservedNames(a.Ctx)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	names := stored
+	for name := range builtinReservedNames {
+		names = append(names, dto.ReservedName{Name: name, Source: dto.ReservedBuiltin})
+	}
+	for name := range a.ReservedNames {
+		if !builtinReservedNames[name] {
+			names = append(names, dto.ReservedName{Name: name, Source: dto.ReservedFile})
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return names[i].Name < names[j].Name })
+	c.Header("Content-Range", contentRange("reserved_names", 0, len(names), len(names)))
+	c.JSON(http.StatusOK, names)
+}
+
+// CreateReservedName reserves a short name. Links that already use it keep
+// it.
+func (a *App) CreateReservedName(c *gin.Context) {
+	var request dto.ReservedNameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondWithBadRequest(c, "invalid request")
+		return
+	}
+	request.Name = strings.ToLower(strings.TrimSpace(request.Name))
+	if validationErrors := request.Validate(); len(validationErrors) > 0 {
+		respondWithValidationErrors(c, validationErrors)
+		return
+	}
+	if a.reservedSource(request.Name) != "" {
+		respondWithValidationError(c, "name", "уже существует")
+		return
+	}
+	name, err := a.Repo.CreateReservedName(a.Ctx, request.Name)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithValidationError(c, "name", "уже существует")
+		return
+	}
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, name)
+}
+
+// DeleteReservedName releases a name reserved through the API. The
+// built-in and file lists can only be changed by a redeploy.
+func (a *App) DeleteReservedName(c *gin.Context) {
+	name := strings.ToLower(c.Param("name"))
+	if source := a.reservedSource(name); source != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "name is reserved by the " + source + " list"})
+		return
+	}
+	err := a.Repo.DeleteReservedName(a.Ctx, name)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "reserved name not found"})
+	case err != nil:
+		respondWithRepoError(c, err)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-278/Internal/dto"
+	"go-project-278/Internal/repository"
+	"go-project-278/Internal/shortcode"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxShortNameAttempts bounds the retries when a generated short name turns
+// out to be taken.
+const maxShortNameAttempts = 5
+
+var errShortNamesExhausted = errors.New("no free short name")
+
+// respondWithGeneratedNameError reports a failed saveWithGeneratedName. A
+// conflict there is not the client's fault, unlike in respondWithSaveError.
+func respondWithGeneratedNameError(c *gin.Context, err error) {
+	if errors.Is(err, errShortNamesExhausted) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not generate a free short name"})
+		return
+	}
+	respondWithRepoError(c, err)
+}
+
+func (a *App) codeGenerator() shortcode.CodeGenerator {
+	if a.Codes != nil {
+		return a.Codes
+	}
+	return shortcode.Random{
+		Length:   shortcode.DefaultLength,
+		Alphabet: shortcode.Config{Lower: a.CaseInsensitive}.Alphabet(),
+	}
+}
+
+// normalizeShortName lower-cases a requested short name in case-insensitive
+// mode. The unique index then also keeps names unique regardless of case;
+// shortNameExists still folds, for names stored before the mode was on.
+func (a *App) normalizeShortName(name string) string {
+	if a.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// shortNameExists reports whether name is taken on host. Only the
+// case-insensitive check looks at custom domains; exact names there are
+// left to the unique index.
+func (a *App) shortNameExists(host, name string) (bool, error) {
+	if a.CaseInsensitive {
+		return a.Repo.CheckShortNameExistsFold(a.Ctx, host, name)
+	}
+	return a.Repo.CheckShortNameExists(a.Ctx, name)
+}
+
+func (a *App) sameShortName(x, y string) bool {
+	if a.CaseInsensitive {
+		return strings.EqualFold(x, y)
+	}
+	return x == y
+}
+
+// saveWithGeneratedName saves link with save (CreateLink or UpdateLink)
+// under generated short names until one is free. The unique index is the
+// judge, so two requests can never end up with the same name; a separate
+// existence check would only race. Reserved codes are drawn again, like
+// taken ones.
+func (a *App) saveWithGeneratedName(link *dto.LinkResponce, save func(context.Context, dto.LinkResponce) error) error {
+	for range maxShortNameAttempts {
+		code, err := a.codeGenerator().Generate(a.Ctx)
+		if err != nil {
+			return err
+		}
+		if !dto.IsValidShortName(code) {
+			return fmt.Errorf("generated short name %q is not valid", code)
+		}
+		reserved, err := a.isReservedName(code)
+		if err != nil {
+			return err
+		}
+		if reserved {
+			continue
+		}
+		link.Short_name = code
+		err = save(a.Ctx, *link)
+		if !errors.Is(err, repository.ErrConflict) {
+			return err
+		}
+	}
+	return errShortNamesExhausted
+}
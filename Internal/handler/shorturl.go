@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"go-project-278/Internal/dto"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// present fills the computed fields of links before they are returned:
+// the expired and active flags and the absolute short_url.
+func (a *App) present(c *gin.Context, links ...*dto.LinkResponce) {
+	markState(links...)
+	for _, link := range links {
+		link.Short_url = a.shortURL(c, link)
+	}
+}
+
+// shortURL is the address link is shared under. Links on a custom domain
+// use that host; the rest live under BaseURL or, without one, under the
+// scheme and host the API itself was reached on.
+func (a *App) shortURL(c *gin.Context, link *dto.LinkResponce) string {
+	path := "/r/" + url.PathEscape(link.Short_name)
+	base, err := url.Parse(a.BaseURL)
+	if a.BaseURL == "" || err != nil {
+		base = &url.URL{Scheme: requestScheme(c), Host: c.Request.Host}
+	}
+	if link.Host != "" {
+		return base.Scheme + "://" + link.Host + path
+	}
+	return strings.TrimSuffix(base.String(), "/") + path
+}
+
+// requestScheme is "https" when the request came over TLS, directly or
+// through a proxy that says so in X-Forwarded-Proto.
+func requestScheme(c *gin.Context) string {
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// requestHost is the Host header as custom domains are registered: lower
+// case, without port or trailing dot. Unlike normalizeHost it keeps "www.",
+// which may well be a domain of its own here.
+func requestHost(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"go-project-278/Internal/dto"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStatsTop = 10
+	maxStatsTop     = 100
+	// The widest range a timeline may cover, per interval.
+	maxDayStatsRange  = 366 * 24 * time.Hour
+	maxHourStatsRange = 31 * 24 * time.Hour
+)
+
+// parseStatsQuery reads from, to, interval and top from the query string.
+// By default the stats cover the last 30 days by day, or the last 24 hours
+// with interval=hour.
+func parseStatsQuery(c *gin.Context, linkID int) (dto.StatsQuery, string) {
+	q := dto.StatsQuery{LinkID: linkID, Interval: c.DefaultQuery("interval", dto.StatsIntervalDay), Top: defaultStatsTop}
+	maxRange := maxDayStatsRange
+	defaultRange := 30 * 24 * time.Hour
+	switch q.Interval {
+	case dto.StatsIntervalDay:
+	case dto.StatsIntervalHour:
+		maxRange = maxHourStatsRange
+		defaultRange = 24 * time.Hour
+	default:
+		return q, "interval must be day or hour"
+	}
+
+	q.To = time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return q, "to must be an RFC 3339 timestamp"
+		}
+		q.To = t.UTC()
+	}
+	q.From = q.To.Add(-defaultRange)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return q, "from must be an RFC 3339 timestamp"
+		}
+		q.From = t.UTC()
+	}
+	if !q.From.Before(q.To) {
+		return q, "from must be before to"
+	}
+	if q.To.Sub(q.From) > maxRange {
+		return q, "range is too long for interval " + q.Interval
+	}
+
+	if raw := c.Query("top"); raw != "" {
+		top, err := strconv.Atoi(raw)
+		if err != nil || top < 1 || top > maxStatsTop {
+			return q, "top must be between 1 and 100"
+		}
+		q.Top = top
+	}
+	return q, ""
+}
+
+// fillTimeline returns one point per bucket between from and to, adding the
+// empty buckets the repository leaves out.
+func fillTimeline(points []dto.TimelinePoint, q dto.StatsQuery) []dto.TimelinePoint {
+	step := 24 * time.Hour
+	start := time.Date(q.From.Year(), q.From.Month(), q.From.Day(), 0, 0, 0, 0, time.UTC)
+	if q.Interval == dto.StatsIntervalHour {
+		step = time.Hour
+		start = q.From.Truncate(time.Hour)
+	}
+	clicks := make(map[int64]int, len(points))
+	for _, p := range points {
+		clicks[p.Time.Unix()] = p.Clicks
+	}
+	filled := []dto.TimelinePoint{}
+	for t := start; t.Before(q.To); t = t.Add(step) {
+		filled = append(filled, dto.TimelinePoint{Time: t, Clicks: clicks[t.Unix()]})
+	}
+	return filled
+}
+
+// LinkStats reports how a link is doing. The click counter and the
+// per-variant numbers cover the link's whole life; everything else is
+// aggregated by the repository over the requested range. Variants that were
+// removed from the link but still have visits are listed after the current
+// ones, without url and weight.
+func (a *App) LinkStats(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondWithBadRequest(c, "invalid id")
+		return
+	}
+	query, problem := parseStatsQuery(c, id)
+	if problem != "" {
+		respondWithBadRequest(c, problem)
+		return
+	}
+	link, err := a.Repo.GetLinkByID(a.Ctx, id)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	visitStats, err := a.Repo.VisitStats(a.Ctx, query)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+	clicks, err := a.Repo.VariantClicks(a.Ctx, id)
+	if err != nil {
+		respondWithRepoError(c, err)
+		return
+	}
+
+	stats := dto.LinkStats{
+		Link_id:    link.Id,
+		Clicks:     link.Clicks,
+		From:       query.From,
+		To:         query.To,
+		Interval:   query.Interval,
+		VisitStats: *visitStats,
+		Variants:   []dto.VariantStats{},
+	}
+	stats.Timeline = fillTimeline(visitStats.Timeline, query)
+	for i, ua := range stats.Top_user_agents {
+		if ua.Family == "" {
+			stats.Top_user_agents[i].Family = "unknown"
+		}
+	}
+	for _, v := range link.Variants {
+		stats.Variants = append(stats.Variants, dto.VariantStats{Variant: v, Clicks: clicks[v.Name]})
+		delete(clicks, v.Name)
+	}
+	removed := make([]string, 0, len(clicks))
+	for name := range clicks {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		stats.Variants = append(stats.Variants, dto.VariantStats{Variant: dto.Variant{Name: name}, Clicks: clicks[name]})
+	}
+	c.JSON(http.StatusOK, stats)
+}
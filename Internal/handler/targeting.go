@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"go-project-278/Internal/dto"
+	"strings"
+)
+
+// device is what targeting needs to know about a visitor, read from the
+// User-Agent header. The checks are deliberately coarse: they only have to
+// tell the app stores apart from the web.
+type device struct {
+	ios, android, windows, macos, linux, mobile bool
+}
+
+func parseUserAgent(ua string) device {
+	var d device
+	d.ios = strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad") || strings.Contains(ua, "iPod")
+	d.android = strings.Contains(ua, "Android")
+	d.windows = strings.Contains(ua, "Windows")
+	d.macos = !d.ios && strings.Contains(ua, "Macintosh")
+	d.linux = !d.android && strings.Contains(ua, "Linux")
+	d.mobile = d.ios || d.android || strings.Contains(ua, "Mobile")
+	return d
+}
+
+// uaFamily names the browser or client behind a User-Agent for the stats.
+// Order matters: most browsers also claim to be Safari or Chrome.
+func uaFamily(ua string) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(lower, "bot"), strings.Contains(lower, "spider"),
+		strings.Contains(lower, "crawler"), strings.Contains(lower, "facebookexternalhit"):
+		return "Bot"
+	case strings.HasPrefix(lower, "curl/"):
+		return "curl"
+	case strings.HasPrefix(lower, "wget/"):
+		return "Wget"
+	case strings.Contains(ua, "Edg/"), strings.Contains(ua, "EdgA/"), strings.Contains(ua, "EdgiOS/"):
+		return "Edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		return "Opera"
+	case strings.Contains(ua, "YaBrowser/"):
+		return "Yandex Browser"
+	case strings.Contains(ua, "SamsungBrowser/"):
+		return "Samsung Internet"
+	case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS/"):
+		return "Firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		return "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		return "Safari"
+	}
+	return "Other"
+}
+
+func (d device) matches(match string) bool {
+	switch match {
+	case dto.TargetIOS:
+		return d.ios
+	case dto.TargetAndroid:
+		return d.android
+	case dto.TargetWindows:
+		return d.windows
+	case dto.TargetMacOS:
+		return d.macos
+	case dto.TargetLinux:
+		return d.linux
+	case dto.TargetMobile:
+		return d.mobile
+	case dto.TargetDesktop:
+		return !d.mobile
+	}
+	return false
+}
+
+// selectTarget picks the link's destination for a visitor: device rules
+// first, then the visitor's country. It returns the URL and the rule that
+// matched ("geo:" and the country code for geo targets), or Original_url
+// and "" when nothing did.
+func selectTarget(link *dto.LinkResponce, userAgent, country string) (string, string) {
+	if len(link.Targets) > 0 {
+		d := parseUserAgent(userAgent)
+		for _, rule := range link.Targets {
+			if d.matches(rule.Match) {
+				return rule.Url, rule.Match
+			}
+		}
+	}
+	if target, ok := link.Geo_targets[country]; ok && country != "" {
+		return target, "geo:" + country
+	}
+	return link.Original_url, ""
+}
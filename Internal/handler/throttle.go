@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// attemptLimiter counts failures per key over a sliding window and locks a
+// key out once it reaches limit. Each lockout in a row lasts twice as long as
+// the one before, up to maxLockout. It lives in process memory, which is
+// enough to slow down guessing against one instance.
+type attemptLimiter struct {
+	mu         sync.Mutex
+	limit      int
+	window     time.Duration
+	maxLockout time.Duration
+	failures   map[string][]time.Time
+	lockouts   map[string]lockout
+}
+
+type lockout struct {
+	until   time.Time
+	strikes int
+}
+
+func newAttemptLimiter(limit int, window, maxLockout time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:      limit,
+		window:     window,
+		maxLockout: maxLockout,
+		failures:   make(map[string][]time.Time),
+		lockouts:   make(map[string]lockout),
+	}
+}
+
+// Allow reports whether key may try again and, if not, how long it has to
+// wait.
+func (l *attemptLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lo, ok := l.lockouts[key]; ok && now.Before(lo.until) {
+		return false, lo.until.Sub(now)
+	}
+	return true, 0
+}
+
+func (l *attemptLimiter) Fail(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	times := append(l.recent(key, now), now)
+	if len(times) < l.limit {
+		l.failures[key] = times
+	} else {
+		delete(l.failures, key)
+		lo := l.lockout(key, now)
+		lo.strikes++
+		lo.until = now.Add(l.lockoutFor(lo.strikes))
+		l.lockouts[key] = lo
+	}
+	if len(l.failures)+len(l.lockouts) > 10000 {
+		for k := range l.failures {
+			l.recent(k, now)
+		}
+		for k := range l.lockouts {
+			l.lockout(k, now)
+		}
+	}
+}
+
+func (l *attemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+	delete(l.lockouts, key)
+}
+
+// recent drops failures older than the window and returns the rest.
+func (l *attemptLimiter) recent(key string, now time.Time) []time.Time {
+	times := l.failures[key]
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = times
+	return times
+}
+
+// lockout returns the lockout of key, forgetting it once key has stayed
+// quiet for maxLockout after it ended.
+func (l *attemptLimiter) lockout(key string, now time.Time) lockout {
+	lo := l.lockouts[key]
+	if !lo.until.IsZero() && now.After(lo.until.Add(l.maxLockout)) {
+		delete(l.lockouts, key)
+		return lockout{}
+	}
+	return lo
+}
+
+// lockoutFor is window doubled for every earlier strike, capped at maxLockout.
+func (l *attemptLimiter) lockoutFor(strikes int) time.Duration {
+	d := l.window
+	for i := 1; i < strikes && d < l.maxLockout; i++ {
+		d *= 2
+	}
+	return min(d, l.maxLockout)
+}
+
+// passwordGuard throttles password guesses for protected links per visitor
+// and link. There is deliberately no cap per link across all visitors: it
+// would let anyone lock the rightful visitors out by posting wrong passwords.
+type passwordGuard struct {
+	perVisitor *attemptLimiter
+}
+
+const passwordWindow = 15 * time.Minute
+
+func newPasswordGuard() *passwordGuard {
+	return &passwordGuard{
+		perVisitor: newAttemptLimiter(5, passwordWindow, 24*time.Hour),
+	}
+}
+
+// allow reports whether the visitor may guess again and, if not, how long
+// it has to wait.
+func (g *passwordGuard) allow(ip string, linkID int, now time.Time) (bool, time.Duration) {
+	return g.perVisitor.Allow(visitorKey(ip, linkID), now)
+}
+
+func (g *passwordGuard) fail(ip string, linkID int, now time.Time) {
+	g.perVisitor.Fail(visitorKey(ip, linkID), now)
+}
+
+func (g *passwordGuard) succeed(ip string, linkID int) {
+	g.perVisitor.Reset(visitorKey(ip, linkID))
+}
+
+func visitorKey(ip string, linkID int) string {
+	return ip + "|" + strconv.Itoa(linkID)
+}
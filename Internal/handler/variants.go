@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"go-project-278/Internal/dto"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// variantCookieMaxAge is how long a sticky visitor keeps their variant.
+const variantCookieMaxAge = 30 * 24 * 60 * 60
+
+func variantCookie(link *dto.LinkResponce) string {
+	return "ab_" + strconv.Itoa(link.Id)
+}
+
+// pickVariant chooses the A/B variant for this visit. With sticky_variants a
+// variant remembered in the visitor's cookie is reused as long as it still
+// exists and has a positive weight; otherwise one is drawn by weight.
+// rememberVariant sets the cookie once the click is accepted.
+func pickVariant(c *gin.Context, link *dto.LinkResponce) dto.Variant {
+	if link.Sticky_variants {
+		if name, err := c.Cookie(variantCookie(link)); err == nil {
+			for _, v := range link.Variants {
+				if v.Name == name && v.Weight > 0 {
+					return v
+				}
+			}
+		}
+	}
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	picked := link.Variants[0]
+	if total > 0 {
+		n := rand.IntN(total)
+		for _, v := range link.Variants {
+			if n < v.Weight {
+				picked = v
+				break
+			}
+			n -= v.Weight
+		}
+	}
+	return picked
+}
+
+// rememberVariant keeps a sticky visitor on the variant they were served.
+func rememberVariant(c *gin.Context, link *dto.LinkResponce, variant string) {
+	if link.Sticky_variants && variant != "" {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(variantCookie(link), variant, variantCookieMaxAge, "/r/", "", false, true)
+	}
+}
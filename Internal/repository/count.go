@@ -0,0 +1,28 @@
+package repository
+
+import "context"
+
+// approxCountThreshold is the table size from which an unfiltered count on
+// Postgres comes from the planner statistics instead of COUNT(*), which has
+// to read the whole table. Autovacuum keeps the estimate reasonably fresh;
+// it only feeds the Content-Range total of the admin UI.
+const approxCountThreshold = 1_000_000
+
+// countRows counts the rows of table matching where (as built by linkWhere
+// or visitWhere). Only a count without a filter may be an estimate.
+func (r *Repository) countRows(ctx context.Context, op, table, where string, args []any) (int, error) {
+	if where == "" && r.dialect == dialectPostgres {
+		// reltuples is -1 before the table was first analyzed; any error
+		// falls back to the exact count as well.
+		var estimate float64
+		err := r.db.QueryRowContext(ctx, `SELECT reltuples FROM pg_class WHERE oid = $1::regclass`, table).Scan(&estimate)
+		if err == nil && estimate >= approxCountThreshold {
+			return int(estimate), nil
+		}
+	}
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+where, args...).Scan(&n); err != nil {
+		return 0, wrapErr(op, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-project-278/Internal/dto"
+)
+
+func (r *Repository) ListDomains(ctx context.Context) ([]dto.Domain, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, host, created_at FROM custom_domains ORDER BY host;`)
+	if err != nil {
+		return nil, wrapErr("list domains", err)
+	}
+	defer rows.Close()
+
+	domains := []dto.Domain{}
+	for rows.Next() {
+		var d dto.Domain
+		if err := rows.Scan(&d.Id, &d.Host, &d.Created_at); err != nil {
+			return nil, wrapErr("scan domain", err)
+		}
+		domains = append(domains, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, wrapErr("rows error", err)
+	}
+	return domains, nil
+}
+
+func (r *Repository) GetDomainByHost(ctx context.Context, host string) (*dto.Domain, error) {
+	var d dto.Domain
+	err := r.db.QueryRowContext(ctx, `SELECT id, host, created_at FROM custom_domains WHERE host = $1;`, host).
+		Scan(&d.Id, &d.Host, &d.Created_at)
+	if err != nil {
+		return nil, wrapErr("get domain", err)
+	}
+	return &d, nil
+}
+
+func (r *Repository) CreateDomain(ctx context.Context, host string) (*dto.Domain, error) {
+	d := dto.Domain{Host: host, Created_at: time.Now().UTC()}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO custom_domains (host, created_at) VALUES ($1, $2) RETURNING id;
+	`, host, d.Created_at).Scan(&d.Id)
+	if err != nil {
+		return nil, wrapErr("create domain", err)
+	}
+	return &d, nil
+}
+
+func (r *Repository) DeleteDomain(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapErr("delete domain", err)
+	}
+	defer tx.Rollback()
+
+	var inUse bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM links WHERE host = (SELECT host FROM custom_domains WHERE id = $1));
+	`, id).Scan(&inUse)
+	if err != nil {
+		return wrapErr("delete domain", err)
+	}
+	if inUse {
+		return fmt.Errorf("delete domain: %w", ErrConflict)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM custom_domains WHERE id = $1;`, id)
+	if err != nil {
+		return wrapErr("delete domain", err)
+	}
+	if err := expectAffected("delete domain", res); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return wrapErr("delete domain", err)
+	}
+	return nil
+}
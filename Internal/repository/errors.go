@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Every PostRepository implementation reports failures through these
+// errors, so callers can use errors.Is instead of inspecting driver messages.
+var (
+	// ErrNotFound means the link (or the link a visit refers to) does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means a uniqueness constraint, such as short_name, was violated.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable means the storage could not be reached; retrying may help.
+	ErrUnavailable = errors.New("storage unavailable")
+)
+
+// wrapErr prefixes err with op and, when the cause is recognised, also wraps
+// the matching sentinel. The driver error stays in the chain for logging.
+func wrapErr(op string, err error) error {
+	if kind := classify(err); kind != nil {
+		return fmt.Errorf("%s: %w: %w", op, kind, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func classify(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505": // unique_violation
+			return ErrConflict
+		case pqErr.Code == "23503": // foreign_key_violation
+			return ErrNotFound
+		case strings.HasPrefix(string(pqErr.Code), "08"), // connection_exception
+			strings.HasPrefix(string(pqErr.Code), "53"),  // insufficient_resources
+			strings.HasPrefix(string(pqErr.Code), "57P"): // operator_intervention
+			return ErrUnavailable
+		}
+		return nil
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ErrConflict
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return ErrNotFound
+		}
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED, sqlite3.SQLITE_IOERR,
+			sqlite3.SQLITE_CANTOPEN, sqlite3.SQLITE_FULL:
+			return ErrUnavailable
+		}
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) {
+		return ErrUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-project-278/Internal/dto"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// linkWhere renders f as a WHERE clause (empty when nothing is filtered)
+// with placeholders numbered from 1. On Postgres the search and the tag
+// filter are written so that the trigram and jsonb indexes apply.
+func (r *Repository) linkWhere(f dto.LinkFilter) (string, []any, error) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.IDs != nil {
+		conds = append(conds, idIn(f.IDs, arg))
+	}
+	if f.Q != "" {
+		pattern := "%" + likeEscaper.Replace(f.Q) + "%"
+		like := "ILIKE"
+		if r.dialect == dialectSQLite {
+			// LIKE ignores case in SQLite, at least for ASCII.
+			like = "LIKE"
+		}
+		conds = append(conds, fmt.Sprintf(`(original_url %[1]s %[2]s ESCAPE '\' OR short_name %[1]s %[3]s ESCAPE '\')`,
+			like, arg(pattern), arg(pattern)))
+	}
+	if f.Domain != "" {
+		conds = append(conds, "original_domain = "+arg(f.Domain))
+	}
+	if f.Tag != "" {
+		if r.dialect == dialectSQLite {
+			conds = append(conds, "EXISTS (SELECT 1 FROM json_each(links.tags) WHERE value = "+arg(f.Tag)+")")
+		} else {
+			tag, err := json.Marshal([]string{f.Tag})
+			if err != nil {
+				return "", nil, err
+			}
+			conds = append(conds, "tags::jsonb @> "+arg(string(tag))+"::jsonb")
+		}
+	}
+	if f.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(nullTime(f.CreatedFrom)))
+	}
+	if f.CreatedTo != nil {
+		conds = append(conds, "created_at <= "+arg(nullTime(f.CreatedTo)))
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// idIn renders "id IN (...)" for ids, or a false condition when there are
+// none, since SQL has no empty IN list.
+func idIn(ids []int, arg func(v any) string) string {
+	if len(ids) == 0 {
+		return "1 = 0"
+	}
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		placeholders[i] = arg(id)
+	}
+	return "id IN (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// linkOrder renders the ORDER BY clause. id breaks ties so that pages do
+// not overlap.
+func linkOrder(sort string, desc bool) (string, error) {
+	if sort == "" {
+		return " ORDER BY id", nil
+	}
+	if !slices.Contains(dto.LinkSortColumns, sort) {
+		return "", fmt.Errorf("unknown sort column %q", sort)
+	}
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	switch sort {
+	case "id":
+		return " ORDER BY id" + dir, nil
+	case "created_at", "expires_at":
+		// Missing timestamps sort as the smallest value in both databases.
+		return " ORDER BY " + sort + " IS NOT NULL" + dir + ", " + sort + dir + ", id" + dir, nil
+	}
+	return " ORDER BY " + sort + dir + ", id" + dir, nil
+}
+
+func (r *Repository) QueryLinks(ctx context.Context, q dto.LinkQuery) ([]*dto.LinkResponce, error) {
+	where, args, err := r.linkWhere(q.LinkFilter)
+	if err != nil {
+		return nil, fmt.Errorf("query links: %w", err)
+	}
+	order, err := linkOrder(q.Sort, q.Desc)
+	if err != nil {
+		return nil, fmt.Errorf("query links: %w", err)
+	}
+	query := `SELECT ` + linkColumns + ` FROM links` + where + order
+	query, args = r.paginate(query, args, q.Start, q.Limit)
+	return r.queryLinks(ctx, query, args...)
+}
+
+func (r *Repository) CountLinks(ctx context.Context, f dto.LinkFilter) (int, error) {
+	where, args, err := r.linkWhere(f)
+	if err != nil {
+		return 0, fmt.Errorf("count links: %w", err)
+	}
+	return r.countRows(ctx, "count links", "links", where, args)
+}
@@ -0,0 +1,715 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"go-project-278/Internal/dto"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a PostRepository kept entirely in process memory.
+// It mirrors the Postgres schema: short names are unique, deleting a link
+// removes its visits, links are ordered by id and visits newest first.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	links       map[int]*dto.LinkResponce
+	visits      map[int]*dto.Visit
+	nextLinkID  int
+	nextVisitID int
+	shortCodeID int64
+
+	domains      map[int]*dto.Domain
+	nextDomainID int
+
+	reserved map[string]time.Time
+}
+
+var _ PostRepository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		links:       make(map[int]*dto.LinkResponce),
+		visits:      make(map[int]*dto.Visit),
+		nextLinkID:  1,
+		nextVisitID: 1,
+
+		domains:      make(map[int]*dto.Domain),
+		nextDomainID: 1,
+
+		reserved: make(map[string]time.Time),
+	}
+}
+
+func (r *MemoryRepository) ListLinks(ctx context.Context) ([]*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedLinks(), nil
+}
+
+func (r *MemoryRepository) ListLinksLimited(ctx context.Context, start, limit int) ([]*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return page(r.sortedLinks(), start, limit), nil
+}
+
+func (r *MemoryRepository) GetLinkByID(ctx context.Context, id int) (*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	link, ok := r.links[id]
+	if !ok {
+		return nil, fmt.Errorf("get link: %w", ErrNotFound)
+	}
+	return cloneLink(link), nil
+}
+
+func (r *MemoryRepository) GetLinkByShortName(ctx context.Context, shortName string) (*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	link := r.findByShortName("", shortName)
+	if link == nil {
+		return nil, fmt.Errorf("get link by short name: %w", ErrNotFound)
+	}
+	return cloneLink(link), nil
+}
+
+func (r *MemoryRepository) ResolveLink(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.findDomain(host) == nil {
+		host = ""
+	}
+	link := r.findByShortName(host, shortName)
+	if link == nil {
+		return nil, fmt.Errorf("resolve link: %w", ErrNotFound)
+	}
+	return cloneLink(link), nil
+}
+
+func (r *MemoryRepository) ResolveLinkFold(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.findDomain(host) == nil {
+		host = ""
+	}
+	link := r.findByShortNameFold(host, shortName)
+	if link == nil {
+		return nil, fmt.Errorf("resolve link: %w", ErrNotFound)
+	}
+	return cloneLink(link), nil
+}
+
+func (r *MemoryRepository) CheckShortNameExistsFold(ctx context.Context, host, shortName string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByShortNameFold(host, shortName) != nil, nil
+}
+
+func (r *MemoryRepository) CheckShortNameExists(ctx context.Context, shortName string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByShortName("", shortName) != nil, nil
+}
+
+func (r *MemoryRepository) NextShortCodeID(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shortCodeID++
+	return r.shortCodeID, nil
+}
+
+func (r *MemoryRepository) CreateLink(ctx context.Context, link dto.LinkResponce) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.findByShortName(link.Host, link.Short_name) != nil {
+		return fmt.Errorf("create link: %w", ErrConflict)
+	}
+	link.Id = r.nextLinkID
+	link.Clicks = 0
+	if link.Created_at == nil {
+		now := time.Now()
+		link.Created_at = &now
+	}
+	if link.Password_hash != nil && *link.Password_hash == "" {
+		link.Password_hash = nil
+	}
+	r.nextLinkID++
+	r.links[link.Id] = cloneLink(&link)
+	return nil
+}
+
+func (r *MemoryRepository) UpdateLink(ctx context.Context, link dto.LinkResponce) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.links[link.Id]
+	if !ok {
+		return fmt.Errorf("update link: %w", ErrNotFound)
+	}
+	if other := r.findByShortName(link.Host, link.Short_name); other != nil && other.Id != link.Id {
+		return fmt.Errorf("update link: %w", ErrConflict)
+	}
+	link.Clicks = current.Clicks
+	link.Created_at = current.Created_at
+	switch {
+	case link.Password_hash == nil:
+		link.Password_hash = current.Password_hash
+	case *link.Password_hash == "":
+		link.Password_hash = nil
+	}
+	r.links[link.Id] = cloneLink(&link)
+	return nil
+}
+
+func (r *MemoryRepository) DeleteLinkByID(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.links[id]; !ok {
+		return fmt.Errorf("delete link: %w", ErrNotFound)
+	}
+	delete(r.links, id)
+	for visitID, v := range r.visits {
+		if v.LinkID == id {
+			delete(r.visits, visitID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RecordVisit(ctx context.Context, v dto.Visit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.links[v.LinkID]; !ok {
+		return fmt.Errorf("record visit: %w", ErrNotFound)
+	}
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	v.Id = r.nextVisitID
+	r.nextVisitID++
+	r.visits[v.Id] = &v
+	return nil
+}
+
+func (r *MemoryRepository) RecordClick(ctx context.Context, v dto.Visit, refusedStatus int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	link, ok := r.links[v.LinkID]
+	if !ok {
+		return false, fmt.Errorf("record click: %w", ErrNotFound)
+	}
+	allowed := link.Max_clicks == nil || link.Clicks < *link.Max_clicks
+	if allowed {
+		link.Clicks++
+	} else {
+		v.Status = refusedStatus
+	}
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	v.Id = r.nextVisitID
+	r.nextVisitID++
+	r.visits[v.Id] = &v
+	return allowed, nil
+}
+
+func (r *MemoryRepository) VariantClicks(ctx context.Context, linkID int) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clicks := make(map[string]int)
+	for _, v := range r.visits {
+		if v.LinkID == linkID && v.Variant != "" && isClick(v) {
+			clicks[v.Variant]++
+		}
+	}
+	return clicks, nil
+}
+
+func (r *MemoryRepository) TopReferrers(ctx context.Context, linkID, limit int) ([]dto.RefererCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, v := range r.visits {
+		if v.LinkID == linkID && v.RefererDomain != "" && isClick(v) {
+			counts[v.RefererDomain]++
+		}
+	}
+	referrers := make([]dto.RefererCount, 0, len(counts))
+	for domain, n := range counts {
+		referrers = append(referrers, dto.RefererCount{Domain: domain, Visits: n})
+	}
+	sort.Slice(referrers, func(i, j int) bool {
+		if referrers[i].Visits != referrers[j].Visits {
+			return referrers[i].Visits > referrers[j].Visits
+		}
+		return referrers[i].Domain < referrers[j].Domain
+	})
+	return page(referrers, 0, limit), nil
+}
+
+func (r *MemoryRepository) VisitStats(ctx context.Context, q dto.StatsQuery) (*dto.VisitStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stats := &dto.VisitStats{Statuses: map[int]int{}}
+	visitors := make(map[string]bool)
+	buckets := make(map[time.Time]int)
+	referrers := make(map[string]int)
+	families := make(map[string]int)
+	for _, v := range r.visits {
+		if v.LinkID != q.LinkID || v.CreatedAt.Before(q.From) || !v.CreatedAt.Before(q.To) {
+			continue
+		}
+		stats.Statuses[v.Status]++
+		if !isClick(v) {
+			continue
+		}
+		stats.Total_clicks++
+		families[v.UAFamily]++
+		if v.RefererDomain != "" {
+			referrers[v.RefererDomain]++
+		}
+		visitors[v.IP+"|"+v.UserAgent] = true
+		bucket := v.CreatedAt.UTC().Truncate(time.Hour)
+		if q.Interval != dto.StatsIntervalHour {
+			bucket = time.Date(bucket.Year(), bucket.Month(), bucket.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		buckets[bucket]++
+	}
+	stats.Unique_visitors = len(visitors)
+
+	stats.Timeline = make([]dto.TimelinePoint, 0, len(buckets))
+	for t, n := range buckets {
+		stats.Timeline = append(stats.Timeline, dto.TimelinePoint{Time: t, Clicks: n})
+	}
+	sort.Slice(stats.Timeline, func(i, j int) bool { return stats.Timeline[i].Time.Before(stats.Timeline[j].Time) })
+
+	stats.Top_referrers = make([]dto.RefererCount, 0, len(referrers))
+	for domain, n := range referrers {
+		stats.Top_referrers = append(stats.Top_referrers, dto.RefererCount{Domain: domain, Visits: n})
+	}
+	sort.Slice(stats.Top_referrers, func(i, j int) bool {
+		a, b := stats.Top_referrers[i], stats.Top_referrers[j]
+		return a.Visits > b.Visits || (a.Visits == b.Visits && a.Domain < b.Domain)
+	})
+	stats.Top_referrers = page(stats.Top_referrers, 0, q.Top)
+
+	stats.Top_user_agents = make([]dto.UserAgentCount, 0, len(families))
+	for family, n := range families {
+		stats.Top_user_agents = append(stats.Top_user_agents, dto.UserAgentCount{Family: family, Visits: n})
+	}
+	sort.Slice(stats.Top_user_agents, func(i, j int) bool {
+		a, b := stats.Top_user_agents[i], stats.Top_user_agents[j]
+		return a.Visits > b.Visits || (a.Visits == b.Visits && a.Family < b.Family)
+	})
+	stats.Top_user_agents = page(stats.Top_user_agents, 0, q.Top)
+	return stats, nil
+}
+
+func (r *MemoryRepository) ListVisits(ctx context.Context) ([]*dto.Visit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedVisits(), nil
+}
+
+func (r *MemoryRepository) ListVisitsLimited(ctx context.Context, start, limit int) ([]*dto.Visit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return page(r.sortedVisits(), start, limit), nil
+}
+
+func (r *MemoryRepository) QueryVisits(ctx context.Context, q dto.VisitQuery) ([]*dto.Visit, error) {
+	if q.Sort != "" && !slices.Contains(dto.VisitSortColumns, q.Sort) {
+		return nil, fmt.Errorf("query visits: unknown sort column %q", q.Sort)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	visits := r.filteredVisits(q.VisitFilter)
+	if q.Sort != "" {
+		sort.SliceStable(visits, func(i, j int) bool {
+			c := compareVisits(visits[i], visits[j], q.Sort)
+			if c == 0 {
+				c = cmp.Compare(visits[i].Id, visits[j].Id)
+			}
+			if q.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if q.Limit <= 0 {
+		return page(visits, q.Start, len(visits)), nil
+	}
+	return page(visits, q.Start, q.Limit), nil
+}
+
+func (r *MemoryRepository) CountVisits(ctx context.Context, f dto.VisitFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.filteredVisits(f)), nil
+}
+
+func (r *MemoryRepository) QueryLinks(ctx context.Context, q dto.LinkQuery) ([]*dto.LinkResponce, error) {
+	if q.Sort != "" && !slices.Contains(dto.LinkSortColumns, q.Sort) {
+		return nil, fmt.Errorf("query links: unknown sort column %q", q.Sort)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	links := r.filteredLinks(q.LinkFilter)
+	if q.Sort != "" {
+		sort.SliceStable(links, func(i, j int) bool {
+			c := compareLinks(links[i], links[j], q.Sort)
+			if c == 0 {
+				c = cmp.Compare(links[i].Id, links[j].Id)
+			}
+			if q.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if q.Limit <= 0 {
+		return page(links, q.Start, len(links)), nil
+	}
+	return page(links, q.Start, q.Limit), nil
+}
+
+func (r *MemoryRepository) CountLinks(ctx context.Context, f dto.LinkFilter) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.filteredLinks(f)), nil
+}
+
+// filteredLinks returns copies of the links matching f, by id.
+func (r *MemoryRepository) filteredLinks(f dto.LinkFilter) []*dto.LinkResponce {
+	all := r.sortedLinks()
+	links := all[:0]
+	q := strings.ToLower(f.Q)
+	for _, link := range all {
+		switch {
+		case f.IDs != nil && !slices.Contains(f.IDs, link.Id),
+			q != "" && !strings.Contains(strings.ToLower(link.Original_url), q) &&
+				!strings.Contains(strings.ToLower(link.Short_name), q),
+			f.Domain != "" && link.Original_domain != f.Domain,
+			f.Tag != "" && !slices.Contains(link.Tags, f.Tag),
+			f.CreatedFrom != nil && (link.Created_at == nil || link.Created_at.Before(*f.CreatedFrom)),
+			f.CreatedTo != nil && (link.Created_at == nil || link.Created_at.After(*f.CreatedTo)):
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// compareLinks orders two links by one of dto.LinkSortColumns. A missing
+// timestamp sorts first, as in the SQL repositories.
+func compareLinks(a, b *dto.LinkResponce, column string) int {
+	compareTime := func(x, y *time.Time) int {
+		switch {
+		case x == nil && y == nil:
+			return 0
+		case x == nil:
+			return -1
+		case y == nil:
+			return 1
+		}
+		return x.Compare(*y)
+	}
+	switch column {
+	case "id":
+		return cmp.Compare(a.Id, b.Id)
+	case "original_url":
+		return cmp.Compare(a.Original_url, b.Original_url)
+	case "short_name":
+		return cmp.Compare(a.Short_name, b.Short_name)
+	case "title":
+		return cmp.Compare(a.Title, b.Title)
+	case "original_domain":
+		return cmp.Compare(a.Original_domain, b.Original_domain)
+	case "clicks":
+		return cmp.Compare(a.Clicks, b.Clicks)
+	case "created_at":
+		return compareTime(a.Created_at, b.Created_at)
+	case "expires_at":
+		return compareTime(a.Expires_at, b.Expires_at)
+	}
+	return 0
+}
+
+func (r *MemoryRepository) VisitsAfter(ctx context.Context, f dto.VisitFilter, afterID, limit int) ([]*dto.Visit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	visits := r.filteredVisits(f)
+	sort.Slice(visits, func(i, j int) bool { return visits[i].Id > visits[j].Id })
+	if afterID > 0 {
+		start := sort.Search(len(visits), func(i int) bool { return visits[i].Id < afterID })
+		visits = visits[start:]
+	}
+	return page(visits, 0, limit), nil
+}
+
+// filteredVisits returns copies of the visits matching f, newest first.
+func (r *MemoryRepository) filteredVisits(f dto.VisitFilter) []*dto.Visit {
+	all := r.sortedVisits()
+	visits := all[:0]
+	for _, v := range all {
+		switch {
+		case f.IDs != nil && !slices.Contains(f.IDs, v.Id),
+			f.LinkID != nil && v.LinkID != *f.LinkID,
+			f.IP != "" && v.IP != f.IP,
+			f.Status != nil && v.Status != *f.Status,
+			f.CreatedFrom != nil && v.CreatedAt.Before(*f.CreatedFrom),
+			f.CreatedTo != nil && v.CreatedAt.After(*f.CreatedTo):
+			continue
+		}
+		visits = append(visits, v)
+	}
+	return visits
+}
+
+// compareVisits orders two visits by one of dto.VisitSortColumns.
+func compareVisits(a, b *dto.Visit, column string) int {
+	switch column {
+	case "id":
+		return cmp.Compare(a.Id, b.Id)
+	case "link_id":
+		return cmp.Compare(a.LinkID, b.LinkID)
+	case "ip":
+		return cmp.Compare(a.IP, b.IP)
+	case "user_agent":
+		return cmp.Compare(a.UserAgent, b.UserAgent)
+	case "status":
+		return cmp.Compare(a.Status, b.Status)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "target":
+		return cmp.Compare(a.Target, b.Target)
+	case "variant":
+		return cmp.Compare(a.Variant, b.Variant)
+	case "country":
+		return cmp.Compare(a.Country, b.Country)
+	case "referer":
+		return cmp.Compare(a.Referer, b.Referer)
+	case "referer_domain":
+		return cmp.Compare(a.RefererDomain, b.RefererDomain)
+	case "ua_family":
+		return cmp.Compare(a.UAFamily, b.UAFamily)
+	}
+	return 0
+}
+
+func (r *MemoryRepository) findByShortName(host, shortName string) *dto.LinkResponce {
+	for _, link := range r.links {
+		if link.Host == host && link.Short_name == shortName {
+			return link
+		}
+	}
+	return nil
+}
+
+// findByShortNameFold prefers the exact match, then the oldest link.
+func (r *MemoryRepository) findByShortNameFold(host, shortName string) *dto.LinkResponce {
+	if link := r.findByShortName(host, shortName); link != nil {
+		return link
+	}
+	var found *dto.LinkResponce
+	for _, link := range r.links {
+		if link.Host == host && strings.EqualFold(link.Short_name, shortName) && (found == nil || link.Id < found.Id) {
+			found = link
+		}
+	}
+	return found
+}
+
+func (r *MemoryRepository) findDomain(host string) *dto.Domain {
+	for _, d := range r.domains {
+		if d.Host == host {
+			return d
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListDomains(ctx context.Context) ([]dto.Domain, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	domains := make([]dto.Domain, 0, len(r.domains))
+	for _, d := range r.domains {
+		domains = append(domains, *d)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Host < domains[j].Host })
+	return domains, nil
+}
+
+func (r *MemoryRepository) GetDomainByHost(ctx context.Context, host string) (*dto.Domain, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d := r.findDomain(host)
+	if d == nil {
+		return nil, fmt.Errorf("get domain: %w", ErrNotFound)
+	}
+	copied := *d
+	return &copied, nil
+}
+
+func (r *MemoryRepository) CreateDomain(ctx context.Context, host string) (*dto.Domain, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.findDomain(host) != nil {
+		return nil, fmt.Errorf("create domain: %w", ErrConflict)
+	}
+	d := &dto.Domain{Id: r.nextDomainID, Host: host, Created_at: time.Now().UTC()}
+	r.nextDomainID++
+	r.domains[d.Id] = d
+	copied := *d
+	return &copied, nil
+}
+
+func (r *MemoryRepository) DeleteDomain(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.domains[id]
+	if !ok {
+		return fmt.Errorf("delete domain: %w", ErrNotFound)
+	}
+	for _, link := range r.links {
+		if link.Host == d.Host {
+			return fmt.Errorf("delete domain: %w", ErrConflict)
+		}
+	}
+	delete(r.domains, id)
+	return nil
+}
+
+func (r *MemoryRepository) ListReservedNames(ctx context.Context) ([]dto.ReservedName, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]dto.ReservedName, 0, len(r.reserved))
+	for name, created := range r.reserved {
+		names = append(names, dto.ReservedName{Name: name, Source: dto.ReservedAPI, Created_at: &created})
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Name < names[j].Name })
+	return names, nil
+}
+
+func (r *MemoryRepository) IsReservedName(ctx context.Context, name string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.reserved[name]
+	return ok, nil
+}
+
+func (r *MemoryRepository) CreateReservedName(ctx context.Context, name string) (*dto.ReservedName, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reserved[name]; ok {
+		return nil, fmt.Errorf("create reserved name: %w", ErrConflict)
+	}
+	created := time.Now().UTC()
+	r.reserved[name] = created
+	return &dto.ReservedName{Name: name, Source: dto.ReservedAPI, Created_at: &created}, nil
+}
+
+func (r *MemoryRepository) DeleteReservedName(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reserved[name]; !ok {
+		return fmt.Errorf("delete reserved name: %w", ErrNotFound)
+	}
+	delete(r.reserved, name)
+	return nil
+}
+
+func (r *MemoryRepository) sortedLinks() []*dto.LinkResponce {
+	links := make([]*dto.LinkResponce, 0, len(r.links))
+	for _, link := range r.links {
+		links = append(links, cloneLink(link))
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Id < links[j].Id })
+	return links
+}
+
+func (r *MemoryRepository) sortedVisits() []*dto.Visit {
+	visits := make([]*dto.Visit, 0, len(r.visits))
+	for _, v := range r.visits {
+		copied := *v
+		visits = append(visits, &copied)
+	}
+	sort.Slice(visits, func(i, j int) bool {
+		if !visits[i].CreatedAt.Equal(visits[j].CreatedAt) {
+			return visits[i].CreatedAt.After(visits[j].CreatedAt)
+		}
+		return visits[i].Id > visits[j].Id
+	})
+	return visits
+}
+
+// cloneLink copies a link together with the values behind its pointer
+// fields, so callers never share state with the store.
+func cloneLink(link *dto.LinkResponce) *dto.LinkResponce {
+	copied := *link
+	if link.Expires_at != nil {
+		t := *link.Expires_at
+		copied.Expires_at = &t
+	}
+	if link.Created_at != nil {
+		t := *link.Created_at
+		copied.Created_at = &t
+	}
+	if link.Starts_at != nil {
+		t := *link.Starts_at
+		copied.Starts_at = &t
+	}
+	if link.Schedule != nil {
+		s := *link.Schedule
+		s.Days = append([]string(nil), link.Schedule.Days...)
+		copied.Schedule = &s
+	}
+	if link.Max_clicks != nil {
+		n := *link.Max_clicks
+		copied.Max_clicks = &n
+	}
+	if link.Password_hash != nil {
+		h := *link.Password_hash
+		copied.Password_hash = &h
+	}
+	if len(link.Targets) > 0 {
+		copied.Targets = append([]dto.TargetRule(nil), link.Targets...)
+	} else {
+		copied.Targets = nil
+	}
+	if len(link.Variants) > 0 {
+		copied.Variants = append([]dto.Variant(nil), link.Variants...)
+	} else {
+		copied.Variants = nil
+	}
+	if len(link.Geo_targets) > 0 {
+		copied.Geo_targets = maps.Clone(link.Geo_targets)
+	} else {
+		copied.Geo_targets = nil
+	}
+	if len(link.Tags) > 0 {
+		copied.Tags = slices.Clone(link.Tags)
+	} else {
+		copied.Tags = nil
+	}
+	return &copied
+}
+
+// page applies OFFSET/LIMIT semantics to an already ordered slice.
+func page[T any](items []T, start, limit int) []T {
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(items) || limit <= 0 {
+		return []T{}
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
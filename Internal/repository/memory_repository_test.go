@@ -0,0 +1,13 @@
+package repository_test
+
+import (
+	"go-project-278/Internal/repository"
+	"go-project-278/Internal/repository/repositorytest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.PostRepository {
+		return repository.NewMemoryRepository()
+	})
+}
@@ -3,76 +3,358 @@ package repository
 import (
 	"go-project-278/Internal/dto"
 	"context"
+	"encoding/json"
 	"fmt"
 	"database/sql"
+	"time"
 )
 type PostRepository interface {
 	ListLinks(ctx context.Context) ([]*dto.LinkResponce, error)
 	GetLinkByID(ctx context.Context, id int) (*dto.LinkResponce, error)
-	GetLinkByShortName(ctx context.Context, shortName string) (*dto.LinkResponce, error) 
+	// GetLinkByShortName and CheckShortNameExists look at the default domain.
+	GetLinkByShortName(ctx context.Context, shortName string) (*dto.LinkResponce, error)
+	// ResolveLink finds the link a request to host is after: one on host when
+	// host is a registered custom domain, otherwise one on the default domain.
+	ResolveLink(ctx context.Context, host, shortName string) (*dto.LinkResponce, error)
+	// ResolveLinkFold and CheckShortNameExistsFold ignore the case of short
+	// names. Where names differ only in case, the exact match wins, then
+	// the oldest link. CheckShortNameExistsFold looks on host, "" for the
+	// default domain.
+	ResolveLinkFold(ctx context.Context, host, shortName string) (*dto.LinkResponce, error)
+	CheckShortNameExistsFold(ctx context.Context, host, shortName string) (bool, error)
 	DeleteLinkByID(ctx context.Context, id int) error
 	CreateLink(ctx context.Context, link dto.LinkResponce) error
 	UpdateLink(ctx context.Context, link dto.LinkResponce) error
 	ListLinksLimited(ctx context.Context, start, limit int) ([]*dto.LinkResponce, error)
 	RecordVisit(ctx context.Context, visit dto.Visit) error 
+	// RecordClick counts a redirect against the link's max_clicks and logs
+	// the visit in one step. When the limit is already used up it returns
+	// false and logs the visit with refusedStatus instead of visit.Status.
+	RecordClick(ctx context.Context, visit dto.Visit, refusedStatus int) (bool, error)
 	ListVisits(ctx context.Context) ([]*dto.Visit, error) 
 	ListVisitsLimited(ctx context.Context, start, limit int) ([]*dto.Visit, error) 
+	// QueryVisits returns the visits matching q's filter in q's order, paged
+	// by q.Start and q.Limit. CountVisits counts all visits matching f; with
+	// an empty filter on a very large table the count may be an estimate.
+	QueryVisits(ctx context.Context, q dto.VisitQuery) ([]*dto.Visit, error)
+	CountVisits(ctx context.Context, f dto.VisitFilter) (int, error)
+	// VisitsAfter pages through the visits matching f by id, newest first:
+	// it returns up to limit visits with an id below afterID, or the newest
+	// ones when afterID is 0. Unlike an offset it stays cheap on deep pages.
+	VisitsAfter(ctx context.Context, f dto.VisitFilter, afterID, limit int) ([]*dto.Visit, error)
+	// VariantClicks counts the clicks per A/B variant of a link: visits
+	// served with status 2xx or 3xx, as in the stats.
+	VariantClicks(ctx context.Context, linkID int) (map[string]int, error)
+	// TopReferrers returns up to limit referring domains of a link, most
+	// clicks first. Refused visits and those without a referrer are left
+	// out.
+	TopReferrers(ctx context.Context, linkID, limit int) ([]dto.RefererCount, error)
+	// VisitStats aggregates a link's visits in the query's time range. The
+	// timeline only holds buckets that have clicks, oldest first.
+	VisitStats(ctx context.Context, q dto.StatsQuery) (*dto.VisitStats, error)
+	// QueryLinks returns the links matching q's filter in q's order, paged
+	// by q.Start and q.Limit. CountLinks counts all links matching f, with
+	// the same caveat as CountVisits.
+	QueryLinks(ctx context.Context, q dto.LinkQuery) ([]*dto.LinkResponce, error)
+	CountLinks(ctx context.Context, f dto.LinkFilter) (int, error)
 	CheckShortNameExists(ctx context.Context, shortName string) (bool, error)
+	// NextShortCodeID returns the next value of the sequence behind the
+	// sequence and hashids short code strategies. Values are never reused.
+	NextShortCodeID(ctx context.Context) (int64, error)
+	ListDomains(ctx context.Context) ([]dto.Domain, error)
+	GetDomainByHost(ctx context.Context, host string) (*dto.Domain, error)
+	CreateDomain(ctx context.Context, host string) (*dto.Domain, error)
+	// DeleteDomain refuses with ErrConflict while links still use the domain.
+	DeleteDomain(ctx context.Context, id int) error
+	// The reserved names added through the API. Names are stored as given;
+	// callers lower-case them.
+	ListReservedNames(ctx context.Context) ([]dto.ReservedName, error)
+	IsReservedName(ctx context.Context, name string) (bool, error)
+	CreateReservedName(ctx context.Context, name string) (*dto.ReservedName, error)
+	DeleteReservedName(ctx context.Context, name string) error
 }
 type Repository struct {
 	db *sql.DB
+	// dialect is only consulted where Postgres and SQLite SQL differ.
+	dialect dialect
 }
 
+type dialect int
+
+const (
+	dialectPostgres dialect = iota
+	dialectSQLite
+)
+
 func NewLinkRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, dialect: dialectPostgres}
 }
 
-func (r *Repository) ListLinks(ctx context.Context) ([]*dto.LinkResponce, error) {
-	query := `
-		SELECT * FROM links;
-	`
-	rows, err := r.db.QueryContext(ctx, query)
+// linkColumns is the column list every link query selects, in scanLink order.
+const linkColumns = `id, original_url, short_name, expires_at, expired_action, expired_url,
+	max_clicks, clicks, redirect_type, password_hash,
+	query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign, targets,
+	variants, sticky_variants, geo_targets,
+	starts_at, schedule, inactive_action, inactive_url,
+	title, preview, created_at, tags, original_domain, host`
+
+// visitColumns is the column list every visit query selects, in scanVisit order.
+const visitColumns = `id, link_id, ip, user_agent, status, created_at, target, variant, country,
+	COALESCE(referer, ''), referer_domain, ua_family, fallback`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row rowScanner) (*dto.LinkResponce, error) {
+	var link dto.LinkResponce
+	var expiresAt sql.NullTime
+	var maxClicks sql.NullInt64
+	var passwordHash sql.NullString
+	var targets, variants, geoTargets, schedule, tags string
+	var startsAt, createdAt sql.NullTime
+	err := row.Scan(
+		&link.Id,
+		&link.Original_url,
+		&link.Short_name,
+		&expiresAt,
+		&link.Expired_action,
+		&link.Expired_url,
+		&maxClicks,
+		&link.Clicks,
+		&link.Redirect_type,
+		&passwordHash,
+		&link.Query_passthrough,
+		&link.Query_precedence,
+		&link.Utm_source,
+		&link.Utm_medium,
+		&link.Utm_campaign,
+		&targets,
+		&variants,
+		&link.Sticky_variants,
+		&geoTargets,
+		&startsAt,
+		&schedule,
+		&link.Inactive_action,
+		&link.Inactive_url,
+		&link.Title,
+		&link.Preview,
+		&createdAt,
+		&tags,
+		&link.Original_domain,
+		&link.Host,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalList("targets", targets, &link.Targets); err != nil {
+		return nil, err
+	}
+	if err := unmarshalList("variants", variants, &link.Variants); err != nil {
+		return nil, err
+	}
+	if err := unmarshalMap("geo_targets", geoTargets, &link.Geo_targets); err != nil {
+		return nil, err
+	}
+	if err := unmarshalList("tags", tags, &link.Tags); err != nil {
+		return nil, err
+	}
+	if startsAt.Valid {
+		t := startsAt.Time
+		link.Starts_at = &t
+	}
+	if createdAt.Valid {
+		t := createdAt.Time
+		link.Created_at = &t
+	}
+	if schedule != "" {
+		link.Schedule = new(dto.Schedule)
+		if err := json.Unmarshal([]byte(schedule), link.Schedule); err != nil {
+			return nil, fmt.Errorf("decode schedule: %w", err)
+		}
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		link.Expires_at = &t
+	}
+	if maxClicks.Valid {
+		n := int(maxClicks.Int64)
+		link.Max_clicks = &n
+	}
+	if passwordHash.Valid {
+		link.Password_hash = &passwordHash.String
+	}
+	return &link, nil
+}
+
+func (r *Repository) queryLinks(ctx context.Context, query string, args ...any) ([]*dto.LinkResponce, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("list links: %w", err)
+		return nil, wrapErr("list links", err)
 	}
 	defer rows.Close()
 	var links []*dto.LinkResponce
 	for rows.Next() {
-		var link dto.LinkResponce
-		err := rows.Scan(
-			&link.Id,
-			&link.Original_url,
-			&link.Short_name,
-			&link.Short_url,
-		)
+		link, err := scanLink(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scan link: %w", err)
+			return nil, wrapErr("scan link", err)
 		}
-		links = append(links, &link)
+		links = append(links, link)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+		return nil, wrapErr("rows error", err)
 	}
 	return links, nil
 }
 
+// nullTime converts an optional timestamp into a query argument. Values are
+// stored in UTC so that SQLite, which keeps timestamps as text, orders them correctly.
+func nullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func nullInt(n *int) any {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+// marshalList encodes a slice for one of the JSON text columns, such as
+// targets or variants. An empty slice is stored as "[]".
+func marshalList[T any](items []T) (string, error) {
+	if len(items) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// unmarshalList decodes a JSON text column; an empty list becomes nil.
+func unmarshalList[T any](column, value string, items *[]T) error {
+	if err := json.Unmarshal([]byte(value), items); err != nil {
+		return fmt.Errorf("decode %s: %w", column, err)
+	}
+	if len(*items) == 0 {
+		*items = nil
+	}
+	return nil
+}
+
+// marshalSchedule encodes the schedule column; no schedule is stored as "".
+func marshalSchedule(s *dto.Schedule) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// marshalMap is marshalList for JSON object columns; empty maps are stored as "{}".
+func marshalMap[V any](m map[string]V) (string, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func unmarshalMap[V any](column, value string, m *map[string]V) error {
+	if err := json.Unmarshal([]byte(value), m); err != nil {
+		return fmt.Errorf("decode %s: %w", column, err)
+	}
+	if len(*m) == 0 {
+		*m = nil
+	}
+	return nil
+}
+
+func scanVisit(row rowScanner) (*dto.Visit, error) {
+	var v dto.Visit
+	if err := row.Scan(&v.Id, &v.LinkID, &v.IP, &v.UserAgent, &v.Status, &v.CreatedAt, &v.Target, &v.Variant, &v.Country,
+		&v.Referer, &v.RefererDomain, &v.UAFamily, &v.Fallback); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *Repository) queryVisits(ctx context.Context, query string, args ...any) ([]*dto.Visit, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapErr("list visits", err)
+	}
+	defer rows.Close()
+
+	var visits []*dto.Visit
+	for rows.Next() {
+		v, err := scanVisit(rows)
+		if err != nil {
+			return nil, wrapErr("scan visit", err)
+		}
+		visits = append(visits, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, wrapErr("rows error", err)
+	}
+	return visits, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertVisit writes a visit through db or an open transaction.
+func insertVisit(ctx context.Context, db execer, v dto.Visit) error {
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO link_visits (link_id, ip, user_agent, status, created_at, target, variant, country,
+			referer, referer_domain, ua_family, fallback)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+	`, v.LinkID, v.IP, v.UserAgent, v.Status, nullTime(&v.CreatedAt), v.Target, v.Variant, v.Country,
+		v.Referer, v.RefererDomain, v.UAFamily, v.Fallback)
+	return err
+}
+
+func nullString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func (r *Repository) ListLinks(ctx context.Context) ([]*dto.LinkResponce, error) {
+	query := `
+		SELECT ` + linkColumns + ` FROM links
+		ORDER BY id;
+	`
+	return r.queryLinks(ctx, query)
+}
+
 func (r *Repository) GetLinkByID(ctx context.Context,id int) (*dto.LinkResponce, error) {
 	query := `
-		SELECT * FROM links
+		SELECT ` + linkColumns + ` FROM links
 		WHERE id = $1;
 	`
-	var link dto.LinkResponce
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&link.Id,
-		&link.Original_url,
-		&link.Short_name,
-		&link.Short_url,
-	)
+	link, err := scanLink(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
-		return nil, fmt.Errorf("get link: %w", err)
+		return nil, wrapErr("get link", err)
 	}
 	
-	return &link, nil
+	return link, nil
 }
 
 func (r *Repository) DeleteLinkByID(ctx context.Context,id int) (error) {
@@ -80,23 +362,58 @@ func (r *Repository) DeleteLinkByID(ctx context.Context,id int) (error) {
 		DELETE FROM links
 		WHERE id = $1;
 	`
-	_, err := r.db.ExecContext(ctx, query, id)
+	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("delete link: %w", err)
+		return wrapErr("delete link", err)
 	}
-	
-	return nil
+	return expectAffected("delete link", res)
 }
 
 func (r *Repository) CreateLink(ctx context.Context,link dto.LinkResponce) (error) {
 	query := `
-		INSERT INTO links (original_url, short_name, short_url)
-		VALUES ($1, $2, $3);
+		INSERT INTO links (original_url, short_name, expires_at, expired_action, expired_url,
+			max_clicks, redirect_type, password_hash,
+			query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign, targets,
+			variants, sticky_variants, geo_targets,
+			starts_at, schedule, inactive_action, inactive_url,
+			title, preview, created_at, tags, original_domain, host)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, $14, $15, $16, $17,
+			$18, $19, $20, $21, $22, $23, $24, $25, $26, $27);
 	`
-	_, err := r.db.ExecContext(ctx, query, link.Original_url, link.Short_name, link.Short_url)
+	createdAt := time.Now()
+	if link.Created_at != nil {
+		createdAt = *link.Created_at
+	}
+	targets, err := marshalList(link.Targets)
+	if err != nil {
+		return fmt.Errorf("create link: %w", err)
+	}
+	variants, err := marshalList(link.Variants)
+	if err != nil {
+		return fmt.Errorf("create link: %w", err)
+	}
+	geoTargets, err := marshalMap(link.Geo_targets)
+	if err != nil {
+		return fmt.Errorf("create link: %w", err)
+	}
+	schedule, err := marshalSchedule(link.Schedule)
 	if err != nil {
 		return fmt.Errorf("create link: %w", err)
 	}
+	tags, err := marshalList(link.Tags)
+	if err != nil {
+		return fmt.Errorf("create link: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx, query, link.Original_url, link.Short_name,
+		nullTime(link.Expires_at), link.Expired_action, link.Expired_url, nullInt(link.Max_clicks),
+		link.Redirect_type, nullString(link.Password_hash),
+		link.Query_passthrough, link.Query_precedence, link.Utm_source, link.Utm_medium, link.Utm_campaign, targets,
+		variants, link.Sticky_variants, geoTargets,
+		nullTime(link.Starts_at), schedule, link.Inactive_action, link.Inactive_url,
+		link.Title, link.Preview, nullTime(&createdAt), tags, link.Original_domain, link.Host)
+	if err != nil {
+		return wrapErr("create link", err)
+	}
 	
 	return  nil
 }
@@ -107,122 +424,263 @@ func (r *Repository) UpdateLink(ctx context.Context,link dto.LinkResponce) (erro
 		SET 
     	original_url = COALESCE($2, original_url),
     	short_name = COALESCE($3, short_name),
-    	short_url = COALESCE($4, short_url)
+    	expires_at = $4,
+    	expired_action = $5,
+    	expired_url = $6,
+    	max_clicks = $7,
+    	redirect_type = $8,
+    	password_hash = NULLIF(COALESCE($9, password_hash), ''),
+    	query_passthrough = $10,
+    	query_precedence = $11,
+    	utm_source = $12,
+    	utm_medium = $13,
+    	utm_campaign = $14,
+    	targets = $15,
+    	variants = $16,
+    	sticky_variants = $17,
+    	geo_targets = $18,
+    	starts_at = $19,
+    	schedule = $20,
+    	inactive_action = $21,
+    	inactive_url = $22,
+    	title = $23,
+    	preview = $24,
+    	tags = $25,
+    	original_domain = $26,
+    	host = $27
 		WHERE id = $1;
 	`
-	_, err :=  r.db.ExecContext(ctx, query, link.Id, link.Original_url,link.Short_name,link.Short_url)
+	targets, err := marshalList(link.Targets)
 	if err != nil {
 		return fmt.Errorf("update link: %w", err)
 	}
-	return  nil
+	variants, err := marshalList(link.Variants)
+	if err != nil {
+		return fmt.Errorf("update link: %w", err)
+	}
+	geoTargets, err := marshalMap(link.Geo_targets)
+	if err != nil {
+		return fmt.Errorf("update link: %w", err)
+	}
+	schedule, err := marshalSchedule(link.Schedule)
+	if err != nil {
+		return fmt.Errorf("update link: %w", err)
+	}
+	tags, err := marshalList(link.Tags)
+	if err != nil {
+		return fmt.Errorf("update link: %w", err)
+	}
+	res, err :=  r.db.ExecContext(ctx, query, link.Id, link.Original_url,link.Short_name,
+		nullTime(link.Expires_at), link.Expired_action, link.Expired_url, nullInt(link.Max_clicks),
+		link.Redirect_type, nullString(link.Password_hash),
+		link.Query_passthrough, link.Query_precedence, link.Utm_source, link.Utm_medium, link.Utm_campaign, targets,
+		variants, link.Sticky_variants, geoTargets,
+		nullTime(link.Starts_at), schedule, link.Inactive_action, link.Inactive_url,
+		link.Title, link.Preview, tags, link.Original_domain, link.Host)
+	if err != nil {
+		return wrapErr("update link", err)
+	}
+	return expectAffected("update link", res)
 }
 func (r *Repository) ListLinksLimited(ctx context.Context, start, limit int) ([]*dto.LinkResponce, error) {
     query := `
-        SELECT id, original_url, short_name, short_url 
+        SELECT ` + linkColumns + `
         FROM links 
         ORDER BY id
         LIMIT $1 OFFSET $2
     `
-    rows, err := r.db.QueryContext(ctx, query, limit, start)
-    if err != nil {
-        return nil, fmt.Errorf("list links: %w", err)
-    }
-    defer rows.Close()
-    
-    var links []*dto.LinkResponce
-    for rows.Next() {
-        var link dto.LinkResponce
-        err := rows.Scan(
-            &link.Id,
-            &link.Original_url,
-            &link.Short_name,
-            &link.Short_url,
-        )
-        if err != nil {
-            return nil, fmt.Errorf("scan link: %w", err)
-        }
-        links = append(links, &link)
-    }
-    
-    if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("rows error: %w", err)
-    }
-    return links, nil
+    return r.queryLinks(ctx, query, limit, start)
 }
 
 func (r *Repository) GetLinkByShortName(ctx context.Context, shortName string) (*dto.LinkResponce, error) {
-	query := `SELECT id, original_url, short_name, short_url FROM links WHERE short_name = $1;`
-	var link dto.LinkResponce
-	err := r.db.QueryRowContext(ctx, query, shortName).Scan(
-		&link.Id, &link.Original_url, &link.Short_name, &link.Short_url,
-	)
+	query := `SELECT ` + linkColumns + ` FROM links WHERE host = '' AND short_name = $1;`
+	link, err := scanLink(r.db.QueryRowContext(ctx, query, shortName))
 	if err != nil {
-		return nil, fmt.Errorf("get link by short name: %w", err)
+		return nil, wrapErr("get link by short name", err)
 	}
-	return &link, nil
+	return link, nil
 }
 
-func (r *Repository) RecordVisit(ctx context.Context, v dto.Visit) error {
+func (r *Repository) ResolveLink(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
+	query := `
+		SELECT ` + linkColumns + ` FROM links
+		WHERE short_name = $2
+			AND host = COALESCE((SELECT host FROM custom_domains WHERE host = $1), '');
+	`
+	link, err := scanLink(r.db.QueryRowContext(ctx, query, host, shortName))
+	if err != nil {
+		return nil, wrapErr("resolve link", err)
+	}
+	return link, nil
+}
+
+func (r *Repository) ResolveLinkFold(ctx context.Context, host, shortName string) (*dto.LinkResponce, error) {
 	query := `
-		INSERT INTO link_visits (link_id, ip, user_agent, referer, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6);
+		SELECT ` + linkColumns + ` FROM links
+		WHERE lower(short_name) = lower($2)
+			AND host = COALESCE((SELECT host FROM custom_domains WHERE host = $1), '')
+		ORDER BY short_name = $2 DESC, id
+		LIMIT 1;
 	`
-	_, err := r.db.ExecContext(ctx, query, v.LinkID, v.IP, v.UserAgent,v.Status, v.CreatedAt)
+	link, err := scanLink(r.db.QueryRowContext(ctx, query, host, shortName))
 	if err != nil {
-		return fmt.Errorf("record visit: %w", err)
+		return nil, wrapErr("resolve link", err)
+	}
+	return link, nil
+}
+
+func (r *Repository) RecordVisit(ctx context.Context, v dto.Visit) error {
+	if err := insertVisit(ctx, r.db, v); err != nil {
+		return wrapErr("record visit", err)
 	}
 	return nil
 }
 
+func (r *Repository) RecordClick(ctx context.Context, v dto.Visit, refusedStatus int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, wrapErr("record click", err)
+	}
+	defer tx.Rollback()
+
+	// The condition is re-checked under the row lock, so concurrent
+	// redirects cannot push clicks past max_clicks.
+	res, err := tx.ExecContext(ctx, `
+		UPDATE links
+		SET clicks = clicks + 1
+		WHERE id = $1 AND (max_clicks IS NULL OR clicks < max_clicks);
+	`, v.LinkID)
+	if err != nil {
+		return false, wrapErr("record click", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, wrapErr("record click", err)
+	}
+	allowed := n > 0
+	if !allowed {
+		v.Status = refusedStatus
+	}
+	if err := insertVisit(ctx, tx, v); err != nil {
+		return false, wrapErr("record click", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, wrapErr("record click", err)
+	}
+	return allowed, nil
+}
+
 func (r *Repository) ListVisits(ctx context.Context) ([]*dto.Visit, error) {
-	query := `SELECT id, link_id, ip, user_agent, referer, status, created_at FROM link_visits ORDER BY created_at DESC;`
-	rows, err := r.db.QueryContext(ctx, query)
+	query := `SELECT ` + visitColumns + ` FROM link_visits ORDER BY created_at DESC, id DESC;`
+	return r.queryVisits(ctx, query)
+}
+
+func (r *Repository) ListVisitsLimited(ctx context.Context, start, limit int) ([]*dto.Visit, error) {
+	query := `
+		SELECT ` + visitColumns + `
+		FROM link_visits 
+		ORDER BY created_at DESC, id DESC 
+		LIMIT $1 OFFSET $2;
+	`
+	return r.queryVisits(ctx, query, limit, start)
+}
+
+func (r *Repository) VariantClicks(ctx context.Context, linkID int) (map[string]int, error) {
+	query := `
+		SELECT variant, COUNT(*)
+		FROM link_visits
+		WHERE link_id = $1 AND variant <> '' AND ` + clickCondition + `
+		GROUP BY variant;
+	`
+	rows, err := r.db.QueryContext(ctx, query, linkID)
 	if err != nil {
-		return nil, err
+		return nil, wrapErr("variant clicks", err)
 	}
 	defer rows.Close()
 
-	var visits []*dto.Visit
+	clicks := make(map[string]int)
 	for rows.Next() {
-		var v dto.Visit
-		if err := rows.Scan(&v.Id, &v.LinkID, &v.IP, &v.UserAgent, &v.Status, &v.CreatedAt); err != nil {
-			return nil, err
+		var variant string
+		var n int
+		if err := rows.Scan(&variant, &n); err != nil {
+			return nil, wrapErr("scan variant clicks", err)
 		}
-		visits = append(visits, &v)
+		clicks[variant] = n
 	}
-	return visits, nil
+	if err = rows.Err(); err != nil {
+		return nil, wrapErr("rows error", err)
+	}
+	return clicks, nil
 }
 
-func (r *Repository) ListVisitsLimited(ctx context.Context, start, limit int) ([]*dto.Visit, error) {
+func (r *Repository) TopReferrers(ctx context.Context, linkID, limit int) ([]dto.RefererCount, error) {
 	query := `
-		SELECT id, link_id, ip, user_agent, referer, status, created_at 
-		FROM link_visits 
-		ORDER BY created_at DESC 
-		LIMIT $1 OFFSET $2;
+		SELECT referer_domain, COUNT(*) AS visits
+		FROM link_visits
+		WHERE link_id = $1 AND referer_domain <> '' AND ` + clickCondition + `
+		GROUP BY referer_domain
+		ORDER BY visits DESC, referer_domain
+		LIMIT $2;
 	`
-	rows, err := r.db.QueryContext(ctx, query, limit, start)
+	rows, err := r.db.QueryContext(ctx, query, linkID, limit)
 	if err != nil {
-		return nil, err
+		return nil, wrapErr("top referrers", err)
 	}
 	defer rows.Close()
 
-	var visits []*dto.Visit
+	referrers := []dto.RefererCount{}
 	for rows.Next() {
-		var v dto.Visit
-		if err := rows.Scan(&v.Id, &v.LinkID, &v.IP, &v.UserAgent,&v.Status, &v.CreatedAt); err != nil {
-			return nil, err
+		var rc dto.RefererCount
+		if err := rows.Scan(&rc.Domain, &rc.Visits); err != nil {
+			return nil, wrapErr("scan referrer", err)
 		}
-		visits = append(visits, &v)
+		referrers = append(referrers, rc)
 	}
-	return visits, nil
+	if err = rows.Err(); err != nil {
+		return nil, wrapErr("rows error", err)
+	}
+	return referrers, nil
 }
 
 func (r *Repository) CheckShortNameExists(ctx context.Context, shortName string) (bool, error) {
-    query := `SELECT EXISTS(SELECT 1 FROM links WHERE short_name = $1);`
+    query := `SELECT EXISTS(SELECT 1 FROM links WHERE host = '' AND short_name = $1);`
     var exists bool
     err := r.db.QueryRowContext(ctx, query, shortName).Scan(&exists)
     if err != nil {
-        return false, fmt.Errorf("check short name exists: %w", err)
+        return false, wrapErr("check short name exists", err)
     }
     return exists, nil
-}
\ No newline at end of file
+}
+
+func (r *Repository) CheckShortNameExistsFold(ctx context.Context, host, shortName string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM links WHERE host = $1 AND lower(short_name) = lower($2));`
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, host, shortName).Scan(&exists); err != nil {
+		return false, wrapErr("check short name exists", err)
+	}
+	return exists, nil
+}
+
+func (r *Repository) NextShortCodeID(ctx context.Context) (int64, error) {
+	query := `SELECT nextval('short_code_seq');`
+	if r.dialect == dialectSQLite {
+		query = `UPDATE short_code_sequence SET value = value + 1 RETURNING value;`
+	}
+	var id int64
+	if err := r.db.QueryRowContext(ctx, query).Scan(&id); err != nil {
+		return 0, wrapErr("next short code id", err)
+	}
+	return id, nil
+}
+
+// expectAffected turns an UPDATE or DELETE that matched no rows into ErrNotFound.
+func expectAffected(op string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return wrapErr(op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
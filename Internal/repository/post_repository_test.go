@@ -0,0 +1,42 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"go-project-278/Internal/repository"
+	"go-project-278/Internal/repository/repositorytest"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// TestPostgresRepository needs a disposable database: every subtest
+// truncates all the tables. Point TEST_DATABASE_URL at one to run it.
+func TestPostgresRepository(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	database, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	provider, err := goose.NewProvider(goose.DialectPostgres, database, os.DirFS("../../db/migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	repositorytest.Run(t, func(t *testing.T) repository.PostRepository {
+		_, err := database.Exec(`TRUNCATE link_visits, links, custom_domains, reserved_names RESTART IDENTITY CASCADE;`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repository.NewLinkRepository(database)
+	})
+}
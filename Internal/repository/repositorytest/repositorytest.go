@@ -0,0 +1,939 @@
+// Package repositorytest is a conformance suite for repository.PostRepository.
+// Every backend runs the same checks from its own _test.go file:
+//
+//	repositorytest.Run(t, func(t *testing.T) repository.PostRepository {
+//		return repository.NewMemoryRepository()
+//	})
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"go-project-278/Internal/dto"
+	"go-project-278/Internal/repository"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty repository. It is called once per subtest.
+type Factory func(t *testing.T) repository.PostRepository
+
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.PostRepository)
+	}{
+		{"CreateAndGetLink", testCreateAndGetLink},
+		{"GetLinkNotFound", testGetLinkNotFound},
+		{"ShortNameUnique", testShortNameUnique},
+		{"CheckShortNameExists", testCheckShortNameExists},
+		{"UpdateLink", testUpdateLink},
+		{"LinkExpirationRoundTrip", testLinkExpirationRoundTrip},
+		{"PasswordHashUpdates", testPasswordHashUpdates},
+		{"ListLinksOrdered", testListLinksOrdered},
+		{"ListLinksLimitedBounds", testListLinksLimitedBounds},
+		{"DeleteLinkCascadesVisits", testDeleteLinkCascadesVisits},
+		{"RecordVisitRoundTrip", testRecordVisitRoundTrip},
+		{"RecordVisitUnknownLink", testRecordVisitUnknownLink},
+		{"RecordClickUnlimited", testRecordClickUnlimited},
+		{"RecordClickLimitConcurrent", testRecordClickLimitConcurrent},
+		{"ListVisitsNewestFirst", testListVisitsNewestFirst},
+		{"VariantClicks", testVariantClicks},
+		{"TopReferrers", testTopReferrers},
+		{"VisitStats", testVisitStats},
+		{"ListVisitsLimitedBounds", testListVisitsLimitedBounds},
+		{"QueryVisits", testQueryVisits},
+		{"QueryLinks", testQueryLinks},
+		{"VisitsAfter", testVisitsAfter},
+		{"NextShortCodeID", testNextShortCodeID},
+		{"Domains", testDomains},
+		{"ShortNamesPerHost", testShortNamesPerHost},
+		{"ReservedNames", testReservedNames},
+		{"ShortNamesFold", testShortNamesFold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+// createLink stores a link and returns it as read back, with its id.
+func createLink(t *testing.T, repo repository.PostRepository, shortName string) *dto.LinkResponce {
+	t.Helper()
+	ctx := context.Background()
+	err := repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url:   "https://example.com/" + shortName,
+		Short_name:     shortName,
+		Expired_action: dto.ExpiredActionGone,
+	})
+	require.NoError(t, err)
+	link, err := repo.GetLinkByShortName(ctx, shortName)
+	require.NoError(t, err)
+	return link
+}
+
+func recordVisit(t *testing.T, repo repository.PostRepository, linkID int, at time.Time) {
+	t.Helper()
+	err := repo.RecordVisit(context.Background(), dto.Visit{
+		LinkID:    linkID,
+		IP:        "203.0.113.7",
+		UserAgent: "conformance",
+		Status:    302,
+		CreatedAt: at,
+	})
+	require.NoError(t, err)
+}
+
+func testCreateAndGetLink(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	created := createLink(t, repo, "first")
+	assert.NotZero(t, created.Id)
+	assert.Equal(t, "https://example.com/first", created.Original_url)
+	assert.Equal(t, "first", created.Short_name)
+	require.NotNil(t, created.Created_at)
+	assert.WithinDuration(t, time.Now(), *created.Created_at, time.Minute)
+
+	byID, err := repo.GetLinkByID(ctx, created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, created, byID)
+}
+
+func testGetLinkNotFound(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	_, err := repo.GetLinkByID(ctx, 424242)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetLinkByShortName(ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	err = repo.UpdateLink(ctx, dto.LinkResponce{Id: 424242, Original_url: "https://example.com", Short_name: "ghost"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, repo.DeleteLinkByID(ctx, 424242), repository.ErrNotFound)
+}
+
+func testShortNameUnique(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	first := createLink(t, repo, "taken")
+	second := createLink(t, repo, "other")
+
+	err := repo.CreateLink(ctx, dto.LinkResponce{Original_url: "https://example.org", Short_name: "taken"})
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	second.Short_name = "taken"
+	assert.ErrorIs(t, repo.UpdateLink(ctx, *second), repository.ErrConflict)
+
+	links, err := repo.ListLinks(ctx)
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, first.Id, links[0].Id)
+	assert.Equal(t, "other", links[1].Short_name)
+}
+
+func testCheckShortNameExists(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	exists, err := repo.CheckShortNameExists(ctx, "promo")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	createLink(t, repo, "promo")
+	exists, err = repo.CheckShortNameExists(ctx, "promo")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testUpdateLink(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "before")
+	link.Original_url = "https://example.net/after"
+	link.Short_name = "after"
+	link.Redirect_type = 308
+	link.Query_passthrough = true
+	link.Query_precedence = dto.QueryPrecedenceRequest
+	link.Utm_source = "newsletter"
+	link.Utm_campaign = "{short_name}"
+	link.Targets = []dto.TargetRule{
+		{Match: dto.TargetIOS, Url: "https://apps.apple.com/app/id1"},
+		{Match: dto.TargetAndroid, Url: "https://play.google.com/store/apps/details?id=x"},
+	}
+	link.Variants = []dto.Variant{
+		{Name: "a", Url: "https://example.net/a", Weight: 3},
+		{Name: "b", Url: "https://example.net/b", Weight: 1},
+	}
+	link.Sticky_variants = true
+	link.Geo_targets = map[string]string{"DE": "https://example.de/after"}
+	link.Schedule = &dto.Schedule{Days: []string{"mon", "fri"}, From: "09:00", To: "18:00", Timezone: "Europe/Moscow"}
+	link.Inactive_action = dto.InactiveActionFallback
+	link.Inactive_url = "https://example.net/closed"
+	link.Title = "После обновления"
+	link.Preview = true
+	link.Tags = []string{"promo", "лето"}
+	link.Original_domain = "example.net"
+	link.Host = "go.team.io"
+	require.NoError(t, repo.UpdateLink(ctx, *link))
+
+	got, err := repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Equal(t, link, got)
+
+	exists, err := repo.CheckShortNameExists(ctx, "before")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testLinkExpirationRoundTrip(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	expiresAt := time.Date(2030, 6, 1, 9, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	startsAt := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url:   "https://example.com/campaign",
+		Short_name:     "campaign",
+		Expires_at:     &expiresAt,
+		Expired_action: dto.ExpiredActionFallback,
+		Expired_url:    "https://example.com/over",
+		Starts_at:      &startsAt,
+	}))
+
+	link, err := repo.GetLinkByShortName(ctx, "campaign")
+	require.NoError(t, err)
+	require.NotNil(t, link.Expires_at)
+	assert.True(t, expiresAt.Equal(*link.Expires_at), "expires_at %v, want %v", link.Expires_at, expiresAt)
+	assert.Equal(t, dto.ExpiredActionFallback, link.Expired_action)
+	assert.Equal(t, "https://example.com/over", link.Expired_url)
+	require.NotNil(t, link.Starts_at)
+	assert.True(t, startsAt.Equal(*link.Starts_at), "starts_at %v, want %v", link.Starts_at, startsAt)
+
+	link.Expires_at = nil
+	link.Expired_action = dto.ExpiredActionGone
+	link.Expired_url = ""
+	require.NoError(t, repo.UpdateLink(ctx, *link))
+	got, err := repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Nil(t, got.Expires_at)
+	assert.Equal(t, dto.ExpiredActionGone, got.Expired_action)
+}
+
+func testPasswordHashUpdates(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	hash := "$2a$10$examplehash"
+	require.NoError(t, repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url:   "https://example.com/secret",
+		Short_name:     "secret",
+		Expired_action: dto.ExpiredActionGone,
+		Password_hash:  &hash,
+	}))
+	link, err := repo.GetLinkByShortName(ctx, "secret")
+	require.NoError(t, err)
+	require.NotNil(t, link.Password_hash)
+	assert.Equal(t, hash, *link.Password_hash)
+
+	// nil keeps the stored hash.
+	link.Password_hash = nil
+	link.Original_url = "https://example.com/secret-v2"
+	require.NoError(t, repo.UpdateLink(ctx, *link))
+	got, err := repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	require.NotNil(t, got.Password_hash)
+	assert.Equal(t, hash, *got.Password_hash)
+
+	// An empty string removes it.
+	empty := ""
+	got.Password_hash = &empty
+	require.NoError(t, repo.UpdateLink(ctx, *got))
+	got, err = repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Nil(t, got.Password_hash)
+
+	plain := createLink(t, repo, "plain")
+	assert.Nil(t, plain.Password_hash)
+}
+
+func testListLinksOrdered(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	links, err := repo.ListLinks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+
+	for i := 0; i < 5; i++ {
+		createLink(t, repo, fmt.Sprintf("link-%d", i))
+	}
+	links, err = repo.ListLinks(ctx)
+	require.NoError(t, err)
+	require.Len(t, links, 5)
+	for i := 1; i < len(links); i++ {
+		assert.Less(t, links[i-1].Id, links[i].Id)
+	}
+}
+
+func testListLinksLimitedBounds(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		createLink(t, repo, fmt.Sprintf("page-%d", i))
+	}
+	all, err := repo.ListLinks(ctx)
+	require.NoError(t, err)
+
+	got, err := repo.ListLinksLimited(ctx, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, all[1].Id, got[0].Id)
+	assert.Equal(t, all[2].Id, got[1].Id)
+
+	got, err = repo.ListLinksLimited(ctx, 3, 10)
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	got, err = repo.ListLinksLimited(ctx, 5, 10)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = repo.ListLinksLimited(ctx, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testDeleteLinkCascadesVisits(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	gone := createLink(t, repo, "gone")
+	kept := createLink(t, repo, "kept")
+	now := time.Now()
+	recordVisit(t, repo, gone.Id, now)
+	recordVisit(t, repo, gone.Id, now)
+	recordVisit(t, repo, kept.Id, now)
+
+	require.NoError(t, repo.DeleteLinkByID(ctx, gone.Id))
+
+	_, err := repo.GetLinkByID(ctx, gone.Id)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	visits, err := repo.ListVisits(ctx)
+	require.NoError(t, err)
+	require.Len(t, visits, 1)
+	assert.Equal(t, kept.Id, visits[0].LinkID)
+}
+
+func testRecordVisitRoundTrip(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "visited")
+	at := time.Date(2025, 3, 14, 15, 9, 26, 0, time.FixedZone("UTC+3", 3*60*60))
+	require.NoError(t, repo.RecordVisit(ctx, dto.Visit{
+		LinkID:        link.Id,
+		IP:            "2001:db8::1",
+		UserAgent:     "Mozilla/5.0 (conformance)",
+		Status:        302,
+		CreatedAt:     at,
+		Target:        dto.TargetAndroid,
+		Country:       "NL",
+		Referer:       "https://www.example.org/post?id=1",
+		RefererDomain: "example.org",
+	}))
+
+	visits, err := repo.ListVisits(ctx)
+	require.NoError(t, err)
+	require.Len(t, visits, 1)
+	v := visits[0]
+	assert.NotZero(t, v.Id)
+	assert.Equal(t, link.Id, v.LinkID)
+	assert.Equal(t, "2001:db8::1", v.IP)
+	assert.Equal(t, "Mozilla/5.0 (conformance)", v.UserAgent)
+	assert.Equal(t, 302, v.Status)
+	assert.Equal(t, dto.TargetAndroid, v.Target)
+	assert.Equal(t, "NL", v.Country)
+	assert.Equal(t, "https://www.example.org/post?id=1", v.Referer)
+	assert.Equal(t, "example.org", v.RefererDomain)
+	assert.True(t, at.Equal(v.CreatedAt), "created_at %v, want %v", v.CreatedAt, at)
+}
+
+func testRecordVisitUnknownLink(t *testing.T, repo repository.PostRepository) {
+	err := repo.RecordVisit(context.Background(), dto.Visit{LinkID: 424242, Status: 302, CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testRecordClickUnlimited(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "open")
+	for i := 0; i < 3; i++ {
+		allowed, err := repo.RecordClick(ctx, dto.Visit{LinkID: link.Id, Status: 302, CreatedAt: time.Now()}, 410)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	got, err := repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, got.Clicks)
+
+	_, err = repo.RecordClick(ctx, dto.Visit{LinkID: 424242, Status: 302, CreatedAt: time.Now()}, 410)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testRecordClickLimitConcurrent(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	maxClicks := 3
+	require.NoError(t, repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url:   "https://example.com/once",
+		Short_name:     "limited",
+		Expired_action: dto.ExpiredActionGone,
+		Max_clicks:     &maxClicks,
+	}))
+	link, err := repo.GetLinkByShortName(ctx, "limited")
+	require.NoError(t, err)
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var allowedCount atomic.Int32
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed, err := repo.RecordClick(ctx, dto.Visit{LinkID: link.Id, Status: 302, CreatedAt: time.Now()}, 410)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if allowed {
+				allowedCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, maxClicks, allowedCount.Load())
+
+	got, err := repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Equal(t, maxClicks, got.Clicks)
+
+	visits, err := repo.ListVisits(ctx)
+	require.NoError(t, err)
+	require.Len(t, visits, attempts)
+	statuses := map[int]int{}
+	for _, v := range visits {
+		statuses[v.Status]++
+	}
+	assert.Equal(t, map[int]int{302: maxClicks, 410: attempts - maxClicks}, statuses)
+
+	// Editing the link must not reset the counter.
+	require.NoError(t, repo.UpdateLink(ctx, *got))
+	got, err = repo.GetLinkByID(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Equal(t, maxClicks, got.Clicks)
+}
+
+func testVariantClicks(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "split")
+	other := createLink(t, repo, "other")
+	for _, v := range []dto.Visit{
+		{LinkID: link.Id, Status: 302, Variant: "a"},
+		{LinkID: link.Id, Status: 302, Variant: "a"},
+		{LinkID: link.Id, Status: 303, Variant: "b"},
+		{LinkID: link.Id, Status: 410, Variant: "b"},
+		// A preview page view is a click too.
+		{LinkID: link.Id, Status: 200, Variant: "b"},
+		{LinkID: link.Id, Status: 302},
+		{LinkID: other.Id, Status: 302, Variant: "a"},
+	} {
+		require.NoError(t, repo.RecordVisit(ctx, v))
+	}
+
+	clicks, err := repo.VariantClicks(ctx, link.Id)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, clicks)
+
+	clicks, err = repo.VariantClicks(ctx, 424242)
+	require.NoError(t, err)
+	assert.Empty(t, clicks)
+}
+
+func testTopReferrers(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "shared")
+	other := createLink(t, repo, "other")
+	for _, v := range []dto.Visit{
+		{LinkID: link.Id, Status: 302, RefererDomain: "t.me"},
+		{LinkID: link.Id, Status: 302, RefererDomain: "t.me"},
+		{LinkID: link.Id, Status: 302, RefererDomain: "google.com"},
+		{LinkID: link.Id, Status: 302, RefererDomain: "bing.com"},
+		{LinkID: link.Id, Status: 302},
+		// Refused visits are not clicks.
+		{LinkID: link.Id, Status: 410, RefererDomain: "spam.example"},
+		{LinkID: link.Id, Status: 410, RefererDomain: "spam.example"},
+		{LinkID: link.Id, Status: 410, RefererDomain: "spam.example"},
+		{LinkID: other.Id, Status: 302, RefererDomain: "vk.com"},
+	} {
+		require.NoError(t, repo.RecordVisit(ctx, v))
+	}
+
+	top, err := repo.TopReferrers(ctx, link.Id, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []dto.RefererCount{{Domain: "t.me", Visits: 2}, {Domain: "bing.com", Visits: 1}}, top)
+
+	top, err = repo.TopReferrers(ctx, 424242, 10)
+	require.NoError(t, err)
+	assert.Empty(t, top)
+}
+
+func testVisitStats(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "stats")
+	other := createLink(t, repo, "other")
+	from := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+	msk := time.FixedZone("UTC+3", 3*60*60)
+	for _, v := range []dto.Visit{
+		// Inside the range, the first one exactly at from.
+		{CreatedAt: from, IP: "198.51.100.1", UserAgent: "chrome-1", UAFamily: "Chrome", Status: 302, RefererDomain: "t.me"},
+		{CreatedAt: from.Add(10 * time.Minute), IP: "198.51.100.1", UserAgent: "chrome-1", UAFamily: "Chrome", Status: 302, RefererDomain: "t.me"},
+		{CreatedAt: from.Add(90 * time.Minute), IP: "198.51.100.2", UserAgent: "firefox-1", UAFamily: "Firefox", Status: 200},
+		{CreatedAt: time.Date(2025, 5, 2, 14, 30, 0, 0, msk), IP: "198.51.100.3", UserAgent: "chrome-2", UAFamily: "Chrome", Status: 302, RefererDomain: "google.com"},
+		{CreatedAt: from.Add(36 * time.Hour), IP: "198.51.100.4", UserAgent: "curl", UAFamily: "curl", Status: 410, RefererDomain: "spam.example"},
+		// A redirect to the expired_url is not a click either.
+		{CreatedAt: from.Add(40 * time.Hour), IP: "198.51.100.5", UserAgent: "chrome-3", UAFamily: "Chrome", Status: 302, RefererDomain: "t.me", Fallback: true},
+		// Outside: before from, exactly at to, and another link.
+		{CreatedAt: from.Add(-time.Second), IP: "198.51.100.9", Status: 302},
+		{CreatedAt: to, IP: "198.51.100.9", Status: 302},
+	} {
+		v.LinkID = link.Id
+		require.NoError(t, repo.RecordVisit(ctx, v))
+	}
+	require.NoError(t, repo.RecordVisit(ctx, dto.Visit{LinkID: other.Id, CreatedAt: from.Add(time.Hour), Status: 302}))
+
+	stats, err := repo.VisitStats(ctx, dto.StatsQuery{LinkID: link.Id, From: from, To: to, Interval: dto.StatsIntervalDay, Top: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Total_clicks)
+	assert.Equal(t, 3, stats.Unique_visitors)
+	assert.Equal(t, map[int]int{200: 1, 302: 4, 410: 1}, stats.Statuses)
+	assert.Equal(t, []dto.RefererCount{{Domain: "t.me", Visits: 2}}, stats.Top_referrers)
+	assert.Equal(t, []dto.UserAgentCount{{Family: "Chrome", Visits: 3}}, stats.Top_user_agents)
+	require.Len(t, stats.Timeline, 2)
+	assert.True(t, from.Equal(stats.Timeline[0].Time), "bucket %v", stats.Timeline[0].Time)
+	assert.Equal(t, 3, stats.Timeline[0].Clicks)
+	assert.True(t, from.Add(24*time.Hour).Equal(stats.Timeline[1].Time), "bucket %v", stats.Timeline[1].Time)
+	assert.Equal(t, 1, stats.Timeline[1].Clicks)
+
+	stats, err = repo.VisitStats(ctx, dto.StatsQuery{LinkID: link.Id, From: from, To: to, Interval: dto.StatsIntervalHour, Top: 10})
+	require.NoError(t, err)
+	var hours []string
+	for _, p := range stats.Timeline {
+		hours = append(hours, fmt.Sprintf("%s=%d", p.Time.UTC().Format("02T15"), p.Clicks))
+	}
+	assert.Equal(t, []string{"01T00=2", "01T01=1", "02T11=1"}, hours)
+	// The refused visit is left out of the top lists.
+	assert.Equal(t, []dto.RefererCount{{Domain: "t.me", Visits: 2}, {Domain: "google.com", Visits: 1}}, stats.Top_referrers)
+	assert.Equal(t, []dto.UserAgentCount{{Family: "Chrome", Visits: 3}, {Family: "Firefox", Visits: 1}}, stats.Top_user_agents)
+
+	stats, err = repo.VisitStats(ctx, dto.StatsQuery{LinkID: 424242, From: from, To: to, Interval: dto.StatsIntervalDay, Top: 10})
+	require.NoError(t, err)
+	assert.Zero(t, stats.Total_clicks)
+	assert.Empty(t, stats.Timeline)
+	assert.Empty(t, stats.Statuses)
+}
+
+func testListVisitsNewestFirst(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "ordered")
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	recordVisit(t, repo, link.Id, base.Add(time.Hour))
+	recordVisit(t, repo, link.Id, base)
+	recordVisit(t, repo, link.Id, base.Add(2*time.Hour))
+	// Same timestamp as the first visit: ties fall back to insertion order, newest first.
+	recordVisit(t, repo, link.Id, base.Add(time.Hour))
+
+	visits, err := repo.ListVisits(ctx)
+	require.NoError(t, err)
+	require.Len(t, visits, 4)
+	assert.True(t, visits[0].CreatedAt.Equal(base.Add(2*time.Hour)))
+	assert.True(t, visits[1].CreatedAt.Equal(base.Add(time.Hour)))
+	assert.True(t, visits[2].CreatedAt.Equal(base.Add(time.Hour)))
+	assert.Greater(t, visits[1].Id, visits[2].Id)
+	assert.True(t, visits[3].CreatedAt.Equal(base))
+}
+
+func testListVisitsLimitedBounds(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "paged")
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		recordVisit(t, repo, link.Id, base.Add(time.Duration(i)*time.Minute))
+	}
+	all, err := repo.ListVisits(ctx)
+	require.NoError(t, err)
+
+	got, err := repo.ListVisitsLimited(ctx, 1, 3)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, all[1].Id, got[0].Id)
+	assert.Equal(t, all[3].Id, got[2].Id)
+
+	got, err = repo.ListVisitsLimited(ctx, 4, 10)
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+
+	got, err = repo.ListVisitsLimited(ctx, 10, 10)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func testQueryVisits(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "queried")
+	other := createLink(t, repo, "other")
+	base := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range []dto.Visit{
+		{LinkID: link.Id, IP: "198.51.100.3", Status: 302},
+		{LinkID: link.Id, IP: "198.51.100.1", Status: 410},
+		{LinkID: link.Id, IP: "198.51.100.2", Status: 302},
+		{LinkID: link.Id, IP: "198.51.100.1", Status: 302},
+		{LinkID: other.Id, IP: "198.51.100.1", Status: 302},
+	} {
+		v.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, repo.RecordVisit(ctx, v))
+	}
+	ips := func(visits []*dto.Visit) []string {
+		out := make([]string, len(visits))
+		for i, v := range visits {
+			out[i] = v.IP
+		}
+		return out
+	}
+
+	status := 302
+	filter := dto.VisitFilter{LinkID: &link.Id, Status: &status}
+	n, err := repo.CountVisits(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	visits, err := repo.QueryVisits(ctx, dto.VisitQuery{VisitFilter: filter})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"}, ips(visits), "newest first by default")
+
+	visits, err = repo.QueryVisits(ctx, dto.VisitQuery{VisitFilter: filter, Sort: "ip", Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.3", "198.51.100.2", "198.51.100.1"}, ips(visits))
+
+	visits, err = repo.QueryVisits(ctx, dto.VisitQuery{VisitFilter: filter, Sort: "ip", Start: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.2"}, ips(visits))
+
+	visits, err = repo.QueryVisits(ctx, dto.VisitQuery{Sort: "created_at", Start: 3})
+	require.NoError(t, err)
+	require.Len(t, visits, 2)
+	assert.Equal(t, other.Id, visits[1].LinkID)
+
+	from, to := base.Add(time.Hour), base.Add(3*time.Hour)
+	n, err = repo.CountVisits(ctx, dto.VisitFilter{IP: "198.51.100.1", CreatedFrom: &from, CreatedTo: &to})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "created range is inclusive at both ends")
+
+	n, err = repo.CountVisits(ctx, dto.VisitFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	// IDs combines with the other fields, on both paging paths.
+	all, err := repo.QueryVisits(ctx, dto.VisitQuery{Sort: "created_at"})
+	require.NoError(t, err)
+	byIDs := dto.VisitFilter{IDs: []int{all[0].Id, all[4].Id}, LinkID: &link.Id}
+	visits, err = repo.QueryVisits(ctx, dto.VisitQuery{VisitFilter: byIDs})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.3"}, ips(visits))
+	visits, err = repo.VisitsAfter(ctx, byIDs, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.3"}, ips(visits))
+	n, err = repo.CountVisits(ctx, dto.VisitFilter{IDs: []int{}})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = repo.QueryVisits(ctx, dto.VisitQuery{Sort: "password_hash"})
+	assert.Error(t, err)
+}
+
+func testQueryLinks(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	base := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, link := range []dto.LinkResponce{
+		{Short_name: "spring-sale", Original_url: "https://shop.example.com/Spring?ref=1", Original_domain: "shop.example.com", Tags: []string{"promo", "spring"}},
+		{Short_name: "docs", Original_url: "https://example.org/docs/100%_free", Original_domain: "example.org"},
+		{Short_name: "summer", Original_url: "https://shop.example.com/summer-SALE", Original_domain: "shop.example.com", Tags: []string{"promo"}},
+		{Short_name: "blog", Original_url: "https://example.org/blog", Original_domain: "example.org", Tags: []string{"news"}},
+	} {
+		created := base.Add(time.Duration(i) * 24 * time.Hour)
+		link.Expired_action = dto.ExpiredActionGone
+		link.Created_at = &created
+		require.NoError(t, repo.CreateLink(ctx, link))
+	}
+	names := func(links []*dto.LinkResponce) []string {
+		out := make([]string, len(links))
+		for i, l := range links {
+			out[i] = l.Short_name
+		}
+		return out
+	}
+	query := func(q dto.LinkQuery) []string {
+		t.Helper()
+		links, err := repo.QueryLinks(ctx, q)
+		require.NoError(t, err)
+		return names(links)
+	}
+
+	// Search is case-insensitive over both original_url and short_name.
+	assert.Equal(t, []string{"spring-sale", "summer"}, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Q: "sale"}}))
+	assert.Equal(t, []string{"spring-sale"}, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Q: "SPRING"}}))
+	// LIKE wildcards in the search are literal.
+	assert.Equal(t, []string{"docs"}, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Q: "100%_"}}))
+	assert.Empty(t, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Q: "1_0"}}))
+
+	assert.Equal(t, []string{"docs", "blog"}, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Domain: "example.org"}}))
+	assert.Equal(t, []string{"summer", "spring-sale"}, query(dto.LinkQuery{
+		LinkFilter: dto.LinkFilter{Tag: "promo"}, Sort: "short_name", Desc: true,
+	}))
+	assert.Empty(t, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{Tag: "prom"}}))
+
+	from, to := base.Add(24*time.Hour), base.Add(2*24*time.Hour)
+	filter := dto.LinkFilter{CreatedFrom: &from, CreatedTo: &to}
+	n, err := repo.CountLinks(ctx, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "created range is inclusive at both ends")
+	n, err = repo.CountLinks(ctx, dto.LinkFilter{Q: "shop", Tag: "promo"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.Equal(t, []string{"blog", "summer", "docs", "spring-sale"}, query(dto.LinkQuery{Sort: "created_at", Desc: true}))
+	assert.Equal(t, []string{"docs", "spring-sale"}, query(dto.LinkQuery{Sort: "short_name", Start: 1, Limit: 2}))
+	assert.Equal(t, []string{"spring-sale", "summer"}, query(dto.LinkQuery{Sort: "short_name", Start: 2}))
+
+	n, err = repo.CountLinks(ctx, dto.LinkFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// IDs picks links by id, unknown ids are skipped, and an empty list
+	// matches none.
+	all, err := repo.QueryLinks(ctx, dto.LinkQuery{})
+	require.NoError(t, err)
+	ids := []int{all[3].Id, all[1].Id, 424242}
+	assert.Equal(t, []string{"docs", "blog"}, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{IDs: ids}}))
+	n, err = repo.CountLinks(ctx, dto.LinkFilter{IDs: ids, Q: "blog"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, query(dto.LinkQuery{LinkFilter: dto.LinkFilter{IDs: []int{}}}))
+
+	_, err = repo.QueryLinks(ctx, dto.LinkQuery{Sort: "password_hash"})
+	assert.Error(t, err)
+}
+
+func testVisitsAfter(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	link := createLink(t, repo, "cursor")
+	other := createLink(t, repo, "other")
+	base := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		recordVisit(t, repo, link.Id, base.Add(time.Duration(-i)*time.Hour))
+		recordVisit(t, repo, other.Id, base)
+	}
+	all, err := repo.QueryVisits(ctx, dto.VisitQuery{VisitFilter: dto.VisitFilter{LinkID: &link.Id}, Sort: "id", Desc: true})
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+
+	// Walk the link's visits two at a time; the pages must add up to all of them.
+	var seen []int
+	afterID := 0
+	for range 4 {
+		page, err := repo.VisitsAfter(ctx, dto.VisitFilter{LinkID: &link.Id}, afterID, 2)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, v := range page {
+			assert.Equal(t, link.Id, v.LinkID)
+			seen = append(seen, v.Id)
+		}
+		afterID = page[len(page)-1].Id
+	}
+	want := make([]int, len(all))
+	for i, v := range all {
+		want[i] = v.Id
+	}
+	assert.Equal(t, want, seen)
+
+	page, err := repo.VisitsAfter(ctx, dto.VisitFilter{}, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Greater(t, page[0].Id, page[1].Id)
+}
+
+func testNextShortCodeID(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	prev := int64(0)
+	for range 3 {
+		id, err := repo.NextShortCodeID(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, id, prev)
+		prev = id
+	}
+}
+
+func testDomains(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	team, err := repo.CreateDomain(ctx, "go.team.io")
+	require.NoError(t, err)
+	assert.NotZero(t, team.Id)
+	_, err = repo.CreateDomain(ctx, "lnk.other.io")
+	require.NoError(t, err)
+	_, err = repo.CreateDomain(ctx, "go.team.io")
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	domains, err := repo.ListDomains(ctx)
+	require.NoError(t, err)
+	require.Len(t, domains, 2)
+	assert.Equal(t, "go.team.io", domains[0].Host)
+	assert.Equal(t, "lnk.other.io", domains[1].Host)
+	assert.False(t, domains[0].Created_at.IsZero())
+
+	got, err := repo.GetDomainByHost(ctx, "go.team.io")
+	require.NoError(t, err)
+	assert.Equal(t, team.Id, got.Id)
+	_, err = repo.GetDomainByHost(ctx, "unknown.io")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url: "https://example.com", Short_name: "abc", Host: "go.team.io", Expired_action: dto.ExpiredActionGone,
+	}))
+	assert.ErrorIs(t, repo.DeleteDomain(ctx, team.Id), repository.ErrConflict)
+	assert.ErrorIs(t, repo.DeleteDomain(ctx, 424242), repository.ErrNotFound)
+	require.NoError(t, repo.DeleteDomain(ctx, domains[1].Id))
+	domains, err = repo.ListDomains(ctx)
+	require.NoError(t, err)
+	assert.Len(t, domains, 1)
+}
+
+func testShortNamesPerHost(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	_, err := repo.CreateDomain(ctx, "go.team.io")
+	require.NoError(t, err)
+	_, err = repo.CreateDomain(ctx, "lnk.other.io")
+	require.NoError(t, err)
+	for host, target := range map[string]string{
+		"":             "https://example.com/default",
+		"go.team.io":   "https://example.com/team",
+		"lnk.other.io": "https://example.com/other",
+	} {
+		require.NoError(t, repo.CreateLink(ctx, dto.LinkResponce{
+			Original_url: target, Short_name: "abc", Host: host, Expired_action: dto.ExpiredActionGone,
+		}), host)
+	}
+	err = repo.CreateLink(ctx, dto.LinkResponce{
+		Original_url: "https://example.com/dup", Short_name: "abc", Host: "go.team.io", Expired_action: dto.ExpiredActionGone,
+	})
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	for host, want := range map[string]string{
+		"go.team.io":   "https://example.com/team",
+		"lnk.other.io": "https://example.com/other",
+		// Hosts that are not custom domains get the default domain.
+		"localhost":        "https://example.com/default",
+		"":                 "https://example.com/default",
+		"short.example.io": "https://example.com/default",
+	} {
+		link, err := repo.ResolveLink(ctx, host, "abc")
+		require.NoError(t, err, host)
+		assert.Equal(t, want, link.Original_url, host)
+	}
+	_, err = repo.ResolveLink(ctx, "go.team.io", "missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	link, err := repo.GetLinkByShortName(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "", link.Host)
+	exists, err := repo.CheckShortNameExists(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func testReservedNames(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	names, err := repo.ListReservedNames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	created, err := repo.CreateReservedName(ctx, "promo")
+	require.NoError(t, err)
+	assert.Equal(t, "promo", created.Name)
+	assert.Equal(t, dto.ReservedAPI, created.Source)
+	require.NotNil(t, created.Created_at)
+	_, err = repo.CreateReservedName(ctx, "billing")
+	require.NoError(t, err)
+	_, err = repo.CreateReservedName(ctx, "promo")
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	names, err = repo.ListReservedNames(ctx)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+	assert.Equal(t, "billing", names[0].Name)
+	assert.Equal(t, "promo", names[1].Name)
+	require.NotNil(t, names[1].Created_at)
+	assert.WithinDuration(t, *created.Created_at, *names[1].Created_at, time.Second)
+
+	reserved, err := repo.IsReservedName(ctx, "promo")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	reserved, err = repo.IsReservedName(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	require.NoError(t, repo.DeleteReservedName(ctx, "promo"))
+	assert.ErrorIs(t, repo.DeleteReservedName(ctx, "promo"), repository.ErrNotFound)
+	reserved, err = repo.IsReservedName(ctx, "promo")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+}
+
+func testShortNamesFold(t *testing.T, repo repository.PostRepository) {
+	ctx := context.Background()
+	_, err := repo.CreateDomain(ctx, "go.team.io")
+	require.NoError(t, err)
+	// Names that only differ in case, as exact matching allowed.
+	for _, link := range []dto.LinkResponce{
+		{Original_url: "https://example.com/first", Short_name: "Promo"},
+		{Original_url: "https://example.com/second", Short_name: "PROMO"},
+		{Original_url: "https://example.com/exact", Short_name: "promo"},
+		{Original_url: "https://example.com/team", Short_name: "Sale", Host: "go.team.io"},
+	} {
+		link.Expired_action = dto.ExpiredActionGone
+		require.NoError(t, repo.CreateLink(ctx, link))
+	}
+
+	for name, want := range map[string]string{
+		"promo": "https://example.com/exact",
+		"PROMO": "https://example.com/second",
+		"pROMo": "https://example.com/first",
+	} {
+		link, err := repo.ResolveLinkFold(ctx, "localhost", name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, link.Original_url, name)
+	}
+	link, err := repo.ResolveLinkFold(ctx, "go.team.io", "SALE")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/team", link.Original_url)
+	_, err = repo.ResolveLinkFold(ctx, "localhost", "sale")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.ResolveLink(ctx, "localhost", "pROMo")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	exists, err := repo.CheckShortNameExistsFold(ctx, "", "PrOmO")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.CheckShortNameExistsFold(ctx, "", "sale")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = repo.CheckShortNameExistsFold(ctx, "go.team.io", "sALE")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.CheckShortNameExists(ctx, "PrOmO")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-project-278/Internal/dto"
+)
+
+func (r *Repository) ListReservedNames(ctx context.Context) ([]dto.ReservedName, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT name, created_at FROM reserved_names ORDER BY name;`)
+	if err != nil {
+		return nil, wrapErr("list reserved names", err)
+	}
+	defer rows.Close()
+
+	names := []dto.ReservedName{}
+	for rows.Next() {
+		n := dto.ReservedName{Source: dto.ReservedAPI}
+		var created time.Time
+		if err := rows.Scan(&n.Name, &created); err != nil {
+			return nil, wrapErr("scan reserved name", err)
+		}
+		n.Created_at = &created
+		names = append(names, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, wrapErr("rows error", err)
+	}
+	return names, nil
+}
+
+func (r *Repository) IsReservedName(ctx context.Context, name string) (bool, error) {
+	var reserved bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM reserved_names WHERE name = $1);`, name).
+		Scan(&reserved)
+	if err != nil {
+		return false, wrapErr("check reserved name", err)
+	}
+	return reserved, nil
+}
+
+func (r *Repository) CreateReservedName(ctx context.Context, name string) (*dto.ReservedName, error) {
+	created := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx, `INSERT INTO reserved_names (name, created_at) VALUES ($1, $2);`, name, created)
+	if err != nil {
+		return nil, wrapErr("create reserved name", err)
+	}
+	return &dto.ReservedName{Name: name, Source: dto.ReservedAPI, Created_at: &created}, nil
+}
+
+func (r *Repository) DeleteReservedName(ctx context.Context, name string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM reserved_names WHERE name = $1;`, name)
+	if err != nil {
+		return wrapErr("delete reserved name", err)
+	}
+	return expectAffected("delete reserved name", res)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"go-project-278/db"
+
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+// sqlitePragmas are applied to every pooled connection: SQLite leaves
+// foreign keys (and therefore ON DELETE CASCADE) off by default.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+// OpenSQLite opens the database file at path, creating it when missing, and
+// applies the migrations from db/migrations/sqlite. Use ":memory:" for a
+// throwaway database.
+func OpenSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	dsn := "file:" + path
+	if strings.Contains(dsn, "?") {
+		dsn += "&" + sqlitePragmas
+	} else {
+		dsn += "?" + sqlitePragmas
+	}
+	database, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// SQLite allows a single writer; one connection also keeps ":memory:"
+	// databases from being private to whichever connection created them.
+	database.SetMaxOpenConns(1)
+
+	migrations, err := fs.Sub(db.SQLiteMigrations, "migrations/sqlite")
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("sqlite migrations: %w", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, database, migrations)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("sqlite migrations: %w", err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("migrate sqlite: %w", err)
+	}
+	return database, nil
+}
+
+// NewSQLiteRepository returns a PostRepository backed by a database opened
+// with OpenSQLite. The queries in post_repository.go stick to SQL that both
+// Postgres and SQLite accept, so the implementation is shared.
+func NewSQLiteRepository(db *sql.DB) *Repository {
+	return &Repository{db: db, dialect: dialectSQLite}
+}
@@ -0,0 +1,20 @@
+package repository_test
+
+import (
+	"context"
+	"go-project-278/Internal/repository"
+	"go-project-278/Internal/repository/repositorytest"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.PostRepository {
+		database, err := repository.OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "links.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { database.Close() })
+		return repository.NewSQLiteRepository(database)
+	})
+}
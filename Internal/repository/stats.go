@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-project-278/Internal/dto"
+)
+
+// clickCondition selects the visits that count as clicks: the ones that
+// were served a redirect or a preview page, fallback redirects aside.
+const clickCondition = `(status BETWEEN 200 AND 399 AND NOT fallback)`
+
+// isClick is clickCondition for the memory repository.
+func isClick(v *dto.Visit) bool {
+	return v.Status >= 200 && v.Status <= 399 && !v.Fallback
+}
+
+// bucketExpr renders created_at truncated to interval as an RFC 3339 UTC
+// string, which both drivers scan the same way.
+func (r *Repository) bucketExpr(interval string) string {
+	if r.dialect == dialectSQLite {
+		if interval == dto.StatsIntervalHour {
+			return `strftime('%Y-%m-%dT%H:00:00Z', created_at)`
+		}
+		return `strftime('%Y-%m-%dT00:00:00Z', created_at)`
+	}
+	if interval == dto.StatsIntervalHour {
+		return `to_char(date_trunc('hour', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD"T"HH24:00:00"Z"')`
+	}
+	return `to_char(date_trunc('day', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD"T00:00:00Z"')`
+}
+
+func (r *Repository) VisitStats(ctx context.Context, q dto.StatsQuery) (*dto.VisitStats, error) {
+	// $1 link, $2 from, $3 to: every query below shares the same range.
+	const where = `link_id = $1 AND created_at >= $2 AND created_at < $3`
+	args := []any{q.LinkID, nullTime(&q.From), nullTime(&q.To)}
+	stats := &dto.VisitStats{
+		Timeline:        []dto.TimelinePoint{},
+		Top_referrers:   []dto.RefererCount{},
+		Top_user_agents: []dto.UserAgentCount{},
+		Statuses:        map[int]int{},
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT COALESCE(ip, '') || '|' || COALESCE(user_agent, ''))
+		FROM link_visits
+		WHERE `+where+` AND `+clickCondition+`;
+	`, args...).Scan(&stats.Total_clicks, &stats.Unique_visitors)
+	if err != nil {
+		return nil, wrapErr("visit stats", err)
+	}
+
+	err = r.eachRow(ctx, `
+		SELECT `+r.bucketExpr(q.Interval)+` AS bucket, COUNT(*)
+		FROM link_visits
+		WHERE `+where+` AND `+clickCondition+`
+		GROUP BY bucket
+		ORDER BY bucket;
+	`, args, func(scan func(...any) error) error {
+		var bucket string
+		var p dto.TimelinePoint
+		if err := scan(&bucket, &p.Clicks); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339, bucket)
+		if err != nil {
+			return fmt.Errorf("parse bucket %q: %w", bucket, err)
+		}
+		p.Time = t
+		stats.Timeline = append(stats.Timeline, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.eachRow(ctx, `
+		SELECT referer_domain, COUNT(*) AS visits
+		FROM link_visits
+		WHERE `+where+` AND referer_domain <> '' AND `+clickCondition+`
+		GROUP BY referer_domain
+		ORDER BY visits DESC, referer_domain
+		LIMIT $4;
+	`, append(args, q.Top), func(scan func(...any) error) error {
+		var rc dto.RefererCount
+		if err := scan(&rc.Domain, &rc.Visits); err != nil {
+			return err
+		}
+		stats.Top_referrers = append(stats.Top_referrers, rc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.eachRow(ctx, `
+		SELECT ua_family, COUNT(*) AS visits
+		FROM link_visits
+		WHERE `+where+` AND `+clickCondition+`
+		GROUP BY ua_family
+		ORDER BY visits DESC, ua_family
+		LIMIT $4;
+	`, append(args, q.Top), func(scan func(...any) error) error {
+		var uc dto.UserAgentCount
+		if err := scan(&uc.Family, &uc.Visits); err != nil {
+			return err
+		}
+		stats.Top_user_agents = append(stats.Top_user_agents, uc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.eachRow(ctx, `
+		SELECT COALESCE(status, 0), COUNT(*)
+		FROM link_visits
+		WHERE `+where+`
+		GROUP BY COALESCE(status, 0);
+	`, args, func(scan func(...any) error) error {
+		var status, n int
+		if err := scan(&status, &n); err != nil {
+			return err
+		}
+		stats.Statuses[status] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// eachRow runs query and hands every row to fn.
+func (r *Repository) eachRow(ctx context.Context, query string, args []any, fn func(scan func(...any) error) error) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return wrapErr("visit stats", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows.Scan); err != nil {
+			return wrapErr("scan visit stats", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapErr("rows error", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-project-278/Internal/dto"
+)
+
+// visitWhere renders f as a WHERE clause (empty when nothing is filtered)
+// with placeholders numbered from 1.
+func visitWhere(f dto.VisitFilter) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.IDs != nil {
+		conds = append(conds, idIn(f.IDs, func(v any) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		}))
+	}
+	if f.LinkID != nil {
+		add("link_id = $%d", *f.LinkID)
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if f.Status != nil {
+		add("status = $%d", *f.Status)
+	}
+	if f.CreatedFrom != nil {
+		add("created_at >= $%d", nullTime(f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		add("created_at <= $%d", nullTime(f.CreatedTo))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// visitOrder renders the ORDER BY clause. id breaks ties so that pages do
+// not overlap.
+func visitOrder(sort string, desc bool) (string, error) {
+	if sort == "" {
+		return " ORDER BY created_at DESC, id DESC", nil
+	}
+	if !slices.Contains(dto.VisitSortColumns, sort) {
+		return "", fmt.Errorf("unknown sort column %q", sort)
+	}
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	switch sort {
+	case "id":
+		return " ORDER BY id" + dir, nil
+	case "referer":
+		// Visits from before the referer was recorded hold NULL, which the
+		// two databases would otherwise place at opposite ends.
+		sort = "COALESCE(referer, '')"
+	}
+	return " ORDER BY " + sort + dir + ", id" + dir, nil
+}
+
+func (r *Repository) QueryVisits(ctx context.Context, q dto.VisitQuery) ([]*dto.Visit, error) {
+	where, args := visitWhere(q.VisitFilter)
+	order, err := visitOrder(q.Sort, q.Desc)
+	if err != nil {
+		return nil, fmt.Errorf("query visits: %w", err)
+	}
+	query := `SELECT ` + visitColumns + ` FROM link_visits` + where + order
+	query, args = r.paginate(query, args, q.Start, q.Limit)
+	return r.queryVisits(ctx, query, args...)
+}
+
+// paginate appends LIMIT/OFFSET for a page starting at start. limit <= 0
+// means no limit, which SQLite can only express as LIMIT -1.
+func (r *Repository) paginate(query string, args []any, start, limit int) (string, []any) {
+	switch {
+	case limit > 0:
+		args = append(args, limit, start)
+		return query + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args)), args
+	case start <= 0:
+		return query, args
+	case r.dialect == dialectSQLite:
+		args = append(args, start)
+		return query + fmt.Sprintf(" LIMIT -1 OFFSET $%d", len(args)), args
+	default:
+		args = append(args, start)
+		return query + fmt.Sprintf(" OFFSET $%d", len(args)), args
+	}
+}
+
+func (r *Repository) VisitsAfter(ctx context.Context, f dto.VisitFilter, afterID, limit int) ([]*dto.Visit, error) {
+	where, args := visitWhere(f)
+	if afterID > 0 {
+		args = append(args, afterID)
+		cond := fmt.Sprintf("id < $%d", len(args))
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+	}
+	args = append(args, limit)
+	query := `SELECT ` + visitColumns + ` FROM link_visits` + where + fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+	return r.queryVisits(ctx, query, args...)
+}
+
+func (r *Repository) CountVisits(ctx context.Context, f dto.VisitFilter) (int, error) {
+	where, args := visitWhere(f)
+	return r.countRows(ctx, "count visits", "link_visits", where, args)
+}
@@ -0,0 +1,267 @@
+// Package shortcode generates the short names of links that are created
+// without one. Four strategies are available:
+//
+//	random    random characters from crypto/rand
+//	sequence  the next value of a database sequence in bijective numeration
+//	hashids   the same sequence value, scrambled with a salt so that
+//	          consecutive links do not get look-alike codes
+//	words     memorable codes such as "brave-otter-42" from embedded
+//	          word lists, for links that are read aloud or printed
+//
+// Length is the exact length of random codes and the minimum length of the
+// sequence codes; words ignores it. A code is only a candidate: the caller
+// stores it and asks for another one when the unique index reports a
+// collision.
+//
+// Codes leave out the characters people mistake for one another when they
+// read or type a code: 0, O, 1, l and I (and digits 0 and 1 in the numbers
+// of words codes). Lower limits them to lower-case letters, for short names
+// that are matched regardless of case.
+package shortcode
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// Alphabet is the base62 digit set, in digit order.
+const Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// The alphabets Config picks from. SafeAlphabet is Alphabet without 0, O,
+// 1, l and I. The lower-case ones drop the capitals; that leaves o and i
+// unambiguous, since 0, 1 and l are gone.
+const (
+	SafeAlphabet      = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	LowerAlphabet     = "0123456789abcdefghijklmnopqrstuvwxyz"
+	LowerSafeAlphabet = "23456789abcdefghijkmnopqrstuvwxyz"
+)
+
+const (
+	StrategyRandom   = "random"
+	StrategySequence = "sequence"
+	StrategyHashids  = "hashids"
+	StrategyWords    = "words"
+)
+
+// DefaultLength keeps random codes short while making collisions rare:
+// 57^7, with SafeAlphabet, is about 2e12.
+const DefaultLength = 7
+
+const (
+	minLength = 4
+	maxLength = 32
+	// maxSequenceLength keeps the offset computed by encodeBijective
+	// within int64.
+	maxSequenceLength = 10
+)
+
+// CodeGenerator proposes short names.
+type CodeGenerator interface {
+	Generate(ctx context.Context) (string, error)
+}
+
+// Sequence hands out increasing positive numbers that are never reused.
+// The repositories implement it.
+type Sequence interface {
+	NextShortCodeID(ctx context.Context) (int64, error)
+}
+
+// Config selects and tunes a strategy. A zero Config is random codes of
+// DefaultLength.
+type Config struct {
+	Strategy string
+	Length   int
+	Salt     string
+	// Lower restricts codes to digits and lower-case letters.
+	Lower bool
+	// AllowConfusable brings back 0, O, 1, l and I, as codes were
+	// generated before they were left out.
+	AllowConfusable bool
+}
+
+// Alphabet is the character set of the random, sequence and hashids codes.
+func (cfg Config) Alphabet() string {
+	switch {
+	case cfg.Lower && cfg.AllowConfusable:
+		return LowerAlphabet
+	case cfg.Lower:
+		return LowerSafeAlphabet
+	case cfg.AllowConfusable:
+		return Alphabet
+	}
+	return SafeAlphabet
+}
+
+// New builds the generator cfg describes. seq is only used by the sequence
+// and hashids strategies.
+func New(cfg Config, seq Sequence) (CodeGenerator, error) {
+	if cfg.Strategy == "" {
+		cfg.Strategy = StrategyRandom
+	}
+	if cfg.Strategy == StrategyWords {
+		words := NewWords()
+		words.AllowConfusable = cfg.AllowConfusable
+		return words, nil
+	}
+	if cfg.Length == 0 {
+		cfg.Length = DefaultLength
+	}
+	limit := maxLength
+	if cfg.Strategy != StrategyRandom {
+		limit = maxSequenceLength
+	}
+	if cfg.Length < minLength || cfg.Length > limit {
+		return nil, fmt.Errorf("short code length %d: want %d to %d for %s", cfg.Length, minLength, limit, cfg.Strategy)
+	}
+	switch cfg.Strategy {
+	case StrategyRandom:
+		return Random{Length: cfg.Length, Alphabet: cfg.Alphabet()}, nil
+	case StrategySequence, StrategyHashids:
+		if seq == nil {
+			return nil, fmt.Errorf("short code strategy %s needs a sequence", cfg.Strategy)
+		}
+		if cfg.Strategy == StrategySequence {
+			return Sequential{Seq: seq, MinLength: cfg.Length, Alphabet: cfg.Alphabet()}, nil
+		}
+		return NewHashids(seq, cfg.Alphabet(), cfg.Salt, cfg.Length), nil
+	}
+	return nil, fmt.Errorf("unknown short code strategy %q", cfg.Strategy)
+}
+
+// orSafe is alphabet, or SafeAlphabet when it is empty.
+func orSafe(alphabet string) string {
+	if alphabet == "" {
+		return SafeAlphabet
+	}
+	return alphabet
+}
+
+// Random draws every character independently from Alphabet, SafeAlphabet
+// if it is empty.
+type Random struct {
+	Length   int
+	Alphabet string
+}
+
+func (g Random) Generate(ctx context.Context) (string, error) {
+	alphabet := orSafe(g.Alphabet)
+	// Dropping the bytes from the largest multiple of the alphabet size on
+	// keeps the characters equally likely.
+	limit := 256 - 256%len(alphabet)
+	code := make([]byte, 0, g.Length)
+	buf := make([]byte, 2*g.Length)
+	for len(code) < g.Length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("random short code: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(code) < g.Length {
+				code = append(code, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+// Sequential encodes the next sequence value in bijective base
+// len(Alphabet), SafeAlphabet if it is empty.
+type Sequential struct {
+	Seq       Sequence
+	MinLength int
+	Alphabet  string
+}
+
+func (g Sequential) Generate(ctx context.Context) (string, error) {
+	n, err := g.Seq.NextShortCodeID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("sequence short code: %w", err)
+	}
+	return encodeBijective(n, g.MinLength, orSafe(g.Alphabet)), nil
+}
+
+// encodeBijective writes n >= 1 in bijective base len(alphabet), where
+// every string is the code of exactly one number. The numbers are shifted
+// past all shorter strings, so 1 becomes the first code of minLength
+// characters.
+func encodeBijective(n int64, minLength int, alphabet string) string {
+	base := int64(len(alphabet))
+	for k, width := 1, base; k < minLength; k, width = k+1, width*base {
+		n += width
+	}
+	var code []byte
+	for n > 0 {
+		n--
+		code = append(code, alphabet[n%base])
+		n /= base
+	}
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+	return string(code)
+}
+
+// Hashids scrambles sequence values the way hashids does: the alphabet is
+// shuffled with the salt, and a leading "lottery" character derived from
+// the number reshuffles it again for the remaining characters. Different
+// numbers still always get different codes.
+type Hashids struct {
+	seq       Sequence
+	salt      string
+	minLength int
+	alphabet  string
+}
+
+func NewHashids(seq Sequence, alphabet, salt string, minLength int) *Hashids {
+	shuffled := []byte(orSafe(alphabet))
+	shuffle(shuffled, salt)
+	return &Hashids{seq: seq, salt: salt, minLength: minLength, alphabet: string(shuffled)}
+}
+
+func (h *Hashids) Generate(ctx context.Context) (string, error) {
+	n, err := h.seq.NextShortCodeID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("hashids short code: %w", err)
+	}
+	return h.Encode(n), nil
+}
+
+// Encode returns the code of n >= 0.
+func (h *Hashids) Encode(n int64) string {
+	base := int64(len(h.alphabet))
+	lottery := h.alphabet[n%base]
+	alphabet := []byte(h.alphabet)
+	shuffle(alphabet, string(lottery)+h.salt)
+
+	var body []byte
+	for v := n; ; v /= base {
+		body = append(body, alphabet[v%base])
+		if v < base {
+			break
+		}
+	}
+	// Padding with the zero digit does not change the value, and all codes
+	// of one lottery character are padded to the same width.
+	for len(body) < h.minLength-1 {
+		body = append(body, alphabet[0])
+	}
+	code := append(make([]byte, 0, len(body)+1), lottery)
+	for i := len(body) - 1; i >= 0; i-- {
+		code = append(code, body[i])
+	}
+	return string(code)
+}
+
+// shuffle is the deterministic shuffle of hashids, keyed by salt.
+func shuffle(alphabet []byte, salt string) {
+	if salt == "" {
+		return
+	}
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+}
@@ -0,0 +1,206 @@
+package shortcode_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-project-278/Internal/dto"
+	"go-project-278/Internal/shortcode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct{ n int64 }
+
+func (c *counter) NextShortCodeID(ctx context.Context) (int64, error) {
+	c.n++
+	return c.n, nil
+}
+
+func TestSequentialBijective(t *testing.T) {
+	// encode returns the code Sequential gives sequence value n.
+	encode := func(n int64, minLength int) string {
+		t.Helper()
+		g := shortcode.Sequential{Seq: &counter{n: n - 1}, MinLength: minLength, Alphabet: shortcode.Alphabet}
+		code, err := g.Generate(context.Background())
+		require.NoError(t, err)
+		return code
+	}
+	assert.Equal(t, "0", encode(1, 1))
+	assert.Equal(t, "z", encode(62, 1))
+	assert.Equal(t, "00", encode(63, 1))
+	assert.Equal(t, "000", encode(1, 3))
+	assert.Equal(t, "001", encode(2, 3))
+
+	seen := make(map[string]bool)
+	for n := int64(1); n <= 5000; n++ {
+		code := encode(n, 2)
+		require.False(t, seen[code], "duplicate %q for %d", code, n)
+		require.GreaterOrEqual(t, len(code), 2)
+		seen[code] = true
+	}
+}
+
+func TestHashidsUniqueAndScrambled(t *testing.T) {
+	h := shortcode.NewHashids(&counter{}, shortcode.Alphabet, "pepper", 6)
+	seen := make(map[string]bool)
+	for n := int64(1); n <= 20000; n++ {
+		code := h.Encode(n)
+		require.False(t, seen[code], "duplicate %q for %d", code, n)
+		require.GreaterOrEqual(t, len(code), 6)
+		seen[code] = true
+	}
+	// Consecutive values differ in more than the last character.
+	a, b := h.Encode(1000), h.Encode(1001)
+	assert.NotEqual(t, a[:len(a)-1], b[:len(b)-1])
+	// The salt changes every code.
+	assert.NotEqual(t, h.Encode(1000), shortcode.NewHashids(&counter{}, shortcode.Alphabet, "salt", 6).Encode(1000))
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	g, err := shortcode.New(shortcode.Config{}, nil)
+	require.NoError(t, err)
+	code, err := g.Generate(ctx)
+	require.NoError(t, err)
+	assert.Len(t, code, shortcode.DefaultLength)
+	assert.Empty(t, strings.Trim(code, shortcode.Alphabet))
+
+	g, err = shortcode.New(shortcode.Config{Strategy: shortcode.StrategySequence, Length: 4}, &counter{})
+	require.NoError(t, err)
+	first, _ := g.Generate(ctx)
+	second, _ := g.Generate(ctx)
+	assert.Equal(t, "2222", first)
+	assert.Equal(t, "2223", second)
+
+	g, err = shortcode.New(shortcode.Config{Strategy: shortcode.StrategyHashids, Length: 5, Salt: "s"}, &counter{})
+	require.NoError(t, err)
+	code, err = g.Generate(ctx)
+	require.NoError(t, err)
+	assert.Len(t, code, 5)
+
+	for _, cfg := range []shortcode.Config{
+		{Strategy: "uuid"},
+		{Length: 3},
+		{Length: 33},
+		{Strategy: shortcode.StrategyHashids, Length: 11},
+	} {
+		_, err := shortcode.New(cfg, &counter{})
+		assert.Error(t, err, cfg)
+	}
+	_, err = shortcode.New(shortcode.Config{Strategy: shortcode.StrategySequence}, nil)
+	assert.Error(t, err)
+}
+
+func TestWords(t *testing.T) {
+	ctx := context.Background()
+	g := shortcode.NewWords()
+	require.NotEmpty(t, g.Adjectives)
+	require.NotEmpty(t, g.Nouns)
+	for _, word := range append(append([]string{}, g.Adjectives...), g.Nouns...) {
+		assert.Regexp(t, `^[a-z]{3,8}$`, word)
+		assert.False(t, g.Blocked(word), "%q is blocked on its own", word)
+	}
+
+	for range 2000 {
+		code, err := g.Generate(ctx)
+		require.NoError(t, err)
+		require.Regexp(t, `^[a-z]+-[a-z]+-[2-9][2-9]$`, code)
+		require.True(t, dto.IsValidShortName(code), code)
+		require.False(t, g.Blocked(code), code)
+	}
+
+	// Words ignores the length, so a configured one does not get in the way.
+	configured, err := shortcode.New(shortcode.Config{Strategy: shortcode.StrategyWords, Length: 4}, nil)
+	require.NoError(t, err)
+	_, err = configured.Generate(ctx)
+	assert.NoError(t, err)
+}
+
+func TestWordsBlocklist(t *testing.T) {
+	ctx := context.Background()
+	g := shortcode.Words{
+		Adjectives: []string{"big", "calm"},
+		Nouns:      []string{"beaver"},
+		Blocklist:  []string{"big-beaver", "-69"},
+	}
+	assert.True(t, g.Blocked("big-beaver-10"))
+	assert.True(t, g.Blocked("calm-beaver-69"))
+	// Terms are also found across word boundaries.
+	assert.True(t, shortcode.Words{Blocklist: []string{"kiwip"}}.Blocked("kiwi-pony-12"))
+	for range 200 {
+		code, err := g.Generate(ctx)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(code, "calm-beaver-"), code)
+		assert.NotEqual(t, "calm-beaver-69", code)
+	}
+
+	g.Blocklist = []string{"beaver"}
+	_, err := g.Generate(ctx)
+	assert.Error(t, err)
+}
+
+func TestAlphabets(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		cfg      shortcode.Config
+		alphabet string
+	}{
+		{shortcode.Config{}, shortcode.SafeAlphabet},
+		{shortcode.Config{Lower: true}, shortcode.LowerSafeAlphabet},
+		{shortcode.Config{AllowConfusable: true}, shortcode.Alphabet},
+		{shortcode.Config{Lower: true, AllowConfusable: true}, shortcode.LowerAlphabet},
+	} {
+		require.Equal(t, tt.alphabet, tt.cfg.Alphabet())
+		for _, strategy := range []string{shortcode.StrategyRandom, shortcode.StrategySequence, shortcode.StrategyHashids} {
+			cfg := tt.cfg
+			cfg.Strategy = strategy
+			g, err := shortcode.New(cfg, &counter{})
+			require.NoError(t, err)
+			seen := make(map[string]bool)
+			for range 500 {
+				code, err := g.Generate(ctx)
+				require.NoError(t, err)
+				require.Empty(t, strings.Trim(code, tt.alphabet), "%s %+v: %q", strategy, cfg, code)
+				if strategy != shortcode.StrategyRandom {
+					require.False(t, seen[code], "duplicate %q", code)
+				}
+				seen[code] = true
+			}
+		}
+	}
+	for _, alphabet := range []string{shortcode.SafeAlphabet, shortcode.LowerSafeAlphabet} {
+		assert.False(t, strings.ContainsAny(alphabet, "0O1lI"), alphabet)
+	}
+	assert.Equal(t, strings.ToLower(shortcode.LowerSafeAlphabet), shortcode.LowerSafeAlphabet)
+
+	// Random codes are spread evenly over a 33-character alphabet too.
+	counts := make(map[rune]int)
+	code, err := shortcode.Random{Length: 33000, Alphabet: shortcode.LowerSafeAlphabet}.Generate(ctx)
+	require.NoError(t, err)
+	for _, c := range code {
+		counts[c]++
+	}
+	require.Len(t, counts, 33)
+	for c, n := range counts {
+		assert.InDelta(t, 1000, n, 200, string(c))
+	}
+}
+
+func TestWordsAllowConfusable(t *testing.T) {
+	g, err := shortcode.New(shortcode.Config{Strategy: shortcode.StrategyWords, AllowConfusable: true}, nil)
+	require.NoError(t, err)
+	numbers := make(map[string]bool)
+	for range 3000 {
+		code, err := g.Generate(context.Background())
+		require.NoError(t, err)
+		numbers[code[strings.LastIndex(code, "-")+1:]] = true
+	}
+	// 10 to 99, less the blocked ones.
+	assert.True(t, numbers["10"] || numbers["11"] || numbers["20"])
+	assert.False(t, numbers["69"])
+	assert.Greater(t, len(numbers), 64)
+}
@@ -0,0 +1,117 @@
+package shortcode
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//go:embed words/*.txt
+var wordFiles embed.FS
+
+// maxWordDraws bounds the draws Words makes to get past the blocklist.
+const maxWordDraws = 100
+
+// Words builds codes such as "brave-otter-42": an adjective, a noun and a
+// two-digit number made of the digits 2 to 9, or any number from 10 to 99
+// with AllowConfusable. Words are lower-case ASCII letters, so the codes
+// stay valid short names. Codes containing a Blocklist term are drawn
+// again.
+type Words struct {
+	Adjectives      []string
+	Nouns           []string
+	Blocklist       []string
+	AllowConfusable bool
+}
+
+// NewWords returns a Words generator with the embedded word lists and
+// blocklist: close to a million codes of at most 20 characters.
+func NewWords() Words {
+	return Words{
+		Adjectives: readWordList("words/adjectives.txt"),
+		Nouns:      readWordList("words/nouns.txt"),
+		Blocklist:  readWordList("words/blocklist.txt"),
+	}
+}
+
+func (g Words) Generate(ctx context.Context) (string, error) {
+	for range maxWordDraws {
+		adjective, err := pick(g.Adjectives)
+		if err != nil {
+			return "", fmt.Errorf("words short code: %w", err)
+		}
+		noun, err := pick(g.Nouns)
+		if err != nil {
+			return "", fmt.Errorf("words short code: %w", err)
+		}
+		number, err := g.number()
+		if err != nil {
+			return "", fmt.Errorf("words short code: %w", err)
+		}
+		code := fmt.Sprintf("%s-%s-%d", adjective, noun, number)
+		if !g.Blocked(code) {
+			return code, nil
+		}
+	}
+	return "", errors.New("words short code: every draw was blocked")
+}
+
+func (g Words) number() (int64, error) {
+	if g.AllowConfusable {
+		n, err := rand.Int(rand.Reader, big.NewInt(90))
+		if err != nil {
+			return 0, err
+		}
+		return 10 + n.Int64(), nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(64))
+	if err != nil {
+		return 0, err
+	}
+	return 22 + n.Int64()/8*10 + n.Int64()%8, nil
+}
+
+// Blocked reports whether code contains a blocklist term, either as it is
+// or with the hyphens removed.
+func (g Words) Blocked(code string) bool {
+	code = strings.ToLower(code)
+	joined := strings.ReplaceAll(code, "-", "")
+	for _, term := range g.Blocklist {
+		if strings.Contains(code, term) || strings.Contains(joined, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func pick(words []string) (string, error) {
+	if len(words) == 0 {
+		return "", errors.New("empty word list")
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", err
+	}
+	return words[i.Int64()], nil
+}
+
+// readWordList reads an embedded list: one entry per line, blank lines and
+// "#" comments skipped.
+func readWordList(name string) []string {
+	data, err := wordFiles.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, strings.ToLower(line))
+		}
+	}
+	return words
+}
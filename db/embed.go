@@ -0,0 +1,10 @@
+// Package db bundles the migration sets that the service applies itself.
+package db
+
+import "embed"
+
+// SQLiteMigrations mirrors db/migrations for the SQLite backend. Postgres
+// migrations are still applied by goose from run.sh.
+//
+//go:embed migrations/sqlite/*.sql
+var SQLiteMigrations embed.FS
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE links ADD COLUMN expired_action VARCHAR(16) NOT NULL DEFAULT 'gone';
+ALTER TABLE links ADD COLUMN expired_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN expired_url;
+ALTER TABLE links DROP COLUMN expired_action;
+ALTER TABLE links DROP COLUMN expires_at;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 302;
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN redirect_type;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN query_passthrough BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE links ADD COLUMN query_precedence VARCHAR(16) NOT NULL DEFAULT 'destination';
+ALTER TABLE links ADD COLUMN utm_source VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN utm_medium VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN utm_campaign VARCHAR(255) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN utm_campaign;
+ALTER TABLE links DROP COLUMN utm_medium;
+ALTER TABLE links DROP COLUMN utm_source;
+ALTER TABLE links DROP COLUMN query_precedence;
+ALTER TABLE links DROP COLUMN query_passthrough;
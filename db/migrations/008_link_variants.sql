@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN variants TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE links ADD COLUMN sticky_variants BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE link_visits ADD COLUMN variant VARCHAR(32) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE link_visits DROP COLUMN variant;
+ALTER TABLE links DROP COLUMN sticky_variants;
+ALTER TABLE links DROP COLUMN variants;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
+-- Links created before this migration have no known creation time.
+ALTER TABLE links ADD COLUMN created_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN created_at;
+ALTER TABLE links DROP COLUMN preview;
+ALTER TABLE links DROP COLUMN title;
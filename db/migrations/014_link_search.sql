@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+ALTER TABLE links ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE links ADD COLUMN original_domain VARCHAR(255) NOT NULL DEFAULT '';
+-- The application derives original_domain on save; existing links get the
+-- same host, lower-cased and without port or "www.".
+UPDATE links SET original_domain = COALESCE(regexp_replace(
+	lower(substring(original_url FROM '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')),
+	'^www\.|\.$', '', 'g'), '');
+-- Trigram indexes serve the ILIKE substring search of GET /api/links.
+CREATE INDEX links_original_url_trgm_idx ON links USING GIN (original_url gin_trgm_ops);
+CREATE INDEX links_short_name_trgm_idx ON links USING GIN (short_name gin_trgm_ops);
+CREATE INDEX links_tags_idx ON links USING GIN ((tags::jsonb) jsonb_path_ops);
+CREATE INDEX links_original_domain_idx ON links (original_domain);
+CREATE INDEX links_created_at_idx ON links (created_at);
+
+-- +goose Down
+DROP INDEX links_created_at_idx;
+DROP INDEX links_original_domain_idx;
+DROP INDEX links_tags_idx;
+DROP INDEX links_short_name_trgm_idx;
+DROP INDEX links_original_url_trgm_idx;
+ALTER TABLE links DROP COLUMN original_domain;
+ALTER TABLE links DROP COLUMN tags;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Keyset pagination of one link's visits walks this index backwards.
+CREATE INDEX link_visits_link_id_idx ON link_visits (link_id, id);
+
+-- +goose Down
+DROP INDEX link_visits_link_id_idx;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Feeds the sequence and hashids short code strategies.
+CREATE SEQUENCE short_code_seq;
+
+-- +goose Down
+DROP SEQUENCE short_code_seq;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE custom_domains (
+    id SERIAL PRIMARY KEY,
+    host VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- A link belongs to one custom domain, or to the default one ('', the host of
+-- BASE_URL). Short names only need to be unique per domain.
+ALTER TABLE links ADD COLUMN host VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE links DROP CONSTRAINT links_short_name_key;
+ALTER TABLE links ADD CONSTRAINT links_host_short_name_key UNIQUE (host, short_name);
+
+-- +goose Down
+ALTER TABLE links DROP CONSTRAINT links_host_short_name_key;
+-- Fails while two domains share a short name.
+ALTER TABLE links ADD CONSTRAINT links_short_name_key UNIQUE (short_name);
+ALTER TABLE links DROP COLUMN host;
+DROP TABLE custom_domains;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Short names reserved through the API, stored lower-case. The built-in
+-- names and the RESERVED_NAMES_FILE list live in the application.
+CREATE TABLE reserved_names (
+    name VARCHAR(255) PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE reserved_names;
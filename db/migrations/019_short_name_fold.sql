@@ -0,0 +1,6 @@
+-- +goose Up
+-- Serves the case-insensitive short name lookups.
+CREATE INDEX links_host_lower_short_name_idx ON links (host, lower(short_name));
+
+-- +goose Down
+DROP INDEX links_host_lower_short_name_idx;
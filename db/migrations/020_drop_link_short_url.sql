@@ -0,0 +1,7 @@
+-- +goose Up
+-- short_url is built for every response from the link's host and the public
+-- base URL, so the column has been left empty since custom domains.
+ALTER TABLE links DROP COLUMN short_url;
+
+-- +goose Down
+ALTER TABLE links ADD COLUMN short_url VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE TABLE links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_url VARCHAR(2048) NOT NULL,
+    short_name VARCHAR(255) UNIQUE NOT NULL,
+    short_url VARCHAR(255) NOT NULL
+);
+CREATE TABLE link_visits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id INTEGER NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    ip VARCHAR(45),
+    user_agent TEXT,
+    referer TEXT,
+    status INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose Down
+DROP TABLE link_visits;
+DROP TABLE links;
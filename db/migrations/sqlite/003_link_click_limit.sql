@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN max_clicks INTEGER;
+ALTER TABLE links ADD COLUMN clicks INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN clicks;
+ALTER TABLE links DROP COLUMN max_clicks;
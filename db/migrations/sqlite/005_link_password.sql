@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN password_hash VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN password_hash;
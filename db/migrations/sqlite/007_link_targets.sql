@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN targets TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE link_visits ADD COLUMN target VARCHAR(32) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE link_visits DROP COLUMN target;
+ALTER TABLE links DROP COLUMN targets;
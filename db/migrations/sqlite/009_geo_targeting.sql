@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN geo_targets TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE link_visits ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE link_visits DROP COLUMN country;
+ALTER TABLE links DROP COLUMN geo_targets;
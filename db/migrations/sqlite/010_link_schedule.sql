@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN starts_at DATETIME;
+ALTER TABLE links ADD COLUMN schedule TEXT NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN inactive_action VARCHAR(16) NOT NULL DEFAULT 'not_found';
+ALTER TABLE links ADD COLUMN inactive_url VARCHAR(2048) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE links DROP COLUMN inactive_url;
+ALTER TABLE links DROP COLUMN inactive_action;
+ALTER TABLE links DROP COLUMN schedule;
+ALTER TABLE links DROP COLUMN starts_at;
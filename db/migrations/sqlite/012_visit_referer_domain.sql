@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE link_visits ADD COLUMN referer_domain VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX link_visits_link_referer_domain_idx ON link_visits (link_id, referer_domain);
+
+-- +goose Down
+DROP INDEX link_visits_link_referer_domain_idx;
+ALTER TABLE link_visits DROP COLUMN referer_domain;
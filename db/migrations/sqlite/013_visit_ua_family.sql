@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE link_visits ADD COLUMN ua_family VARCHAR(32) NOT NULL DEFAULT '';
+CREATE INDEX link_visits_link_created_at_idx ON link_visits (link_id, created_at);
+
+-- +goose Down
+DROP INDEX link_visits_link_created_at_idx;
+ALTER TABLE link_visits DROP COLUMN ua_family;
@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE links ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE links ADD COLUMN original_domain VARCHAR(255) NOT NULL DEFAULT '';
+-- The application derives original_domain on save; existing links get the
+-- same host, lower-cased and without port or "www.". SQLite has no regular
+-- expressions, so the host is cut out of the URL step by step.
+UPDATE links SET original_domain = lower(substr(original_url, instr(original_url, '://') + 3))
+	WHERE instr(original_url, '://') > 0;
+UPDATE links SET original_domain = replace(replace(original_domain, '?', '/'), '#', '/');
+UPDATE links SET original_domain = substr(original_domain, 1, instr(original_domain || '/', '/') - 1);
+UPDATE links SET original_domain = substr(original_domain, instr(original_domain, '@') + 1);
+UPDATE links SET original_domain = substr(original_domain, 1, instr(original_domain || ':', ':') - 1);
+UPDATE links SET original_domain = substr(original_domain, 5) WHERE original_domain LIKE 'www.%';
+UPDATE links SET original_domain = rtrim(original_domain, '.');
+-- SQLite cannot index a substring search; LIKE scans the table there.
+CREATE INDEX links_original_domain_idx ON links (original_domain);
+CREATE INDEX links_created_at_idx ON links (created_at);
+
+-- +goose Down
+DROP INDEX links_created_at_idx;
+DROP INDEX links_original_domain_idx;
+ALTER TABLE links DROP COLUMN original_domain;
+ALTER TABLE links DROP COLUMN tags;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Feeds the sequence and hashids short code strategies. SQLite has no
+-- sequences, so a one-row counter stands in.
+CREATE TABLE short_code_sequence (value INTEGER NOT NULL);
+INSERT INTO short_code_sequence (value) VALUES (0);
+
+-- +goose Down
+DROP TABLE short_code_sequence;
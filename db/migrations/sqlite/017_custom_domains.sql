@@ -0,0 +1,116 @@
+-- +goose NO TRANSACTION
+-- SQLite cannot drop the UNIQUE of short_name in place, so links is rebuilt.
+-- Foreign keys must be off while the old table is dropped, or the visits
+-- would cascade away with it; that pragma has no effect inside a
+-- transaction, hence NO TRANSACTION and the explicit BEGIN/COMMIT.
+
+-- +goose Up
+CREATE TABLE custom_domains (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    host VARCHAR(255) UNIQUE NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+PRAGMA foreign_keys = OFF;
+BEGIN;
+-- A link belongs to one custom domain, or to the default one ('', the host of
+-- BASE_URL). Short names only need to be unique per domain.
+CREATE TABLE links_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_url VARCHAR(2048) NOT NULL,
+    short_name VARCHAR(255) NOT NULL,
+    short_url VARCHAR(255) NOT NULL,
+    expires_at DATETIME,
+    expired_action VARCHAR(16) NOT NULL DEFAULT 'gone',
+    expired_url VARCHAR(2048) NOT NULL DEFAULT '',
+    max_clicks INTEGER,
+    clicks INTEGER NOT NULL DEFAULT 0,
+    redirect_type INTEGER NOT NULL DEFAULT 302,
+    password_hash VARCHAR(255),
+    query_passthrough BOOLEAN NOT NULL DEFAULT 0,
+    query_precedence VARCHAR(16) NOT NULL DEFAULT 'destination',
+    utm_source VARCHAR(255) NOT NULL DEFAULT '',
+    utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+    utm_campaign VARCHAR(255) NOT NULL DEFAULT '',
+    targets TEXT NOT NULL DEFAULT '[]',
+    variants TEXT NOT NULL DEFAULT '[]',
+    sticky_variants BOOLEAN NOT NULL DEFAULT 0,
+    geo_targets TEXT NOT NULL DEFAULT '{}',
+    starts_at DATETIME,
+    schedule TEXT NOT NULL DEFAULT '',
+    inactive_action VARCHAR(16) NOT NULL DEFAULT 'not_found',
+    inactive_url VARCHAR(2048) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    preview BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME,
+    tags TEXT NOT NULL DEFAULT '[]',
+    original_domain VARCHAR(255) NOT NULL DEFAULT '',
+    host VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE (host, short_name)
+);
+INSERT INTO links_new (id, original_url, short_name, short_url, expires_at, expired_action, expired_url, max_clicks, clicks,
+    redirect_type, password_hash, query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign,
+    targets, variants, sticky_variants, geo_targets, starts_at, schedule, inactive_action, inactive_url, title,
+    preview, created_at, tags, original_domain)
+SELECT id, original_url, short_name, short_url, expires_at, expired_action, expired_url, max_clicks, clicks,
+    redirect_type, password_hash, query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign,
+    targets, variants, sticky_variants, geo_targets, starts_at, schedule, inactive_action, inactive_url, title,
+    preview, created_at, tags, original_domain
+FROM links;
+DROP TABLE links;
+ALTER TABLE links_new RENAME TO links;
+CREATE INDEX links_original_domain_idx ON links (original_domain);
+CREATE INDEX links_created_at_idx ON links (created_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE links_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_url VARCHAR(2048) NOT NULL,
+    short_name VARCHAR(255) UNIQUE NOT NULL,
+    short_url VARCHAR(255) NOT NULL,
+    expires_at DATETIME,
+    expired_action VARCHAR(16) NOT NULL DEFAULT 'gone',
+    expired_url VARCHAR(2048) NOT NULL DEFAULT '',
+    max_clicks INTEGER,
+    clicks INTEGER NOT NULL DEFAULT 0,
+    redirect_type INTEGER NOT NULL DEFAULT 302,
+    password_hash VARCHAR(255),
+    query_passthrough BOOLEAN NOT NULL DEFAULT 0,
+    query_precedence VARCHAR(16) NOT NULL DEFAULT 'destination',
+    utm_source VARCHAR(255) NOT NULL DEFAULT '',
+    utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+    utm_campaign VARCHAR(255) NOT NULL DEFAULT '',
+    targets TEXT NOT NULL DEFAULT '[]',
+    variants TEXT NOT NULL DEFAULT '[]',
+    sticky_variants BOOLEAN NOT NULL DEFAULT 0,
+    geo_targets TEXT NOT NULL DEFAULT '{}',
+    starts_at DATETIME,
+    schedule TEXT NOT NULL DEFAULT '',
+    inactive_action VARCHAR(16) NOT NULL DEFAULT 'not_found',
+    inactive_url VARCHAR(2048) NOT NULL DEFAULT '',
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    preview BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME,
+    tags TEXT NOT NULL DEFAULT '[]',
+    original_domain VARCHAR(255) NOT NULL DEFAULT ''
+);
+-- Fails while two domains share a short name.
+INSERT INTO links_old (id, original_url, short_name, short_url, expires_at, expired_action, expired_url, max_clicks, clicks,
+    redirect_type, password_hash, query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign,
+    targets, variants, sticky_variants, geo_targets, starts_at, schedule, inactive_action, inactive_url, title,
+    preview, created_at, tags, original_domain)
+SELECT id, original_url, short_name, short_url, expires_at, expired_action, expired_url, max_clicks, clicks,
+    redirect_type, password_hash, query_passthrough, query_precedence, utm_source, utm_medium, utm_campaign,
+    targets, variants, sticky_variants, geo_targets, starts_at, schedule, inactive_action, inactive_url, title,
+    preview, created_at, tags, original_domain
+FROM links;
+DROP TABLE links;
+ALTER TABLE links_old RENAME TO links;
+CREATE INDEX links_original_domain_idx ON links (original_domain);
+CREATE INDEX links_created_at_idx ON links (created_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
+DROP TABLE custom_domains;
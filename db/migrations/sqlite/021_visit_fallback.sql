@@ -0,0 +1,7 @@
+-- +goose Up
+-- Marks the redirects to expired_url and inactive_url, which are not clicks
+-- on the link. Fallback visits recorded before this cannot be told apart.
+ALTER TABLE link_visits ADD COLUMN fallback BOOLEAN NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE link_visits DROP COLUMN fallback;
@@ -1,6 +1,6 @@
 -- name: CreateLink :one
-INSERT INTO links (original_url, short_name, short_url)
-VALUES ($1, $2, $3);
+INSERT INTO links (original_url, short_name)
+VALUES ($1, $2);
 
 -- name: GetLinkByID :one
 SELECT * FROM links
@@ -13,8 +13,7 @@ SELECT * FROM links;
 UPDATE links
 SET 
     original_url = COALESCE($2, original_url),
-    short_name = COALESCE($3, short_name),
-    short_url = COALESCE($4, short_url)
+    short_name = COALESCE($3, short_name)
 WHERE id = $1;
 
 -- name: DeleteLink :exec